@@ -0,0 +1,78 @@
+package notifyop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Discord posts Message as embed to Discord webhook
+type Discord struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewDiscord gives Discord posting to webhook url
+func NewDiscord(url string, c *http.Client) *Discord {
+	return &Discord{url: url, httpClient: c}
+}
+
+// DiscordPayload is body of Discord webhook
+type DiscordPayload struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+// DiscordEmbed is rich content of Discord message
+type DiscordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+}
+
+// DiscordEmbedField is field of DiscordEmbed
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Notify implements Notifier
+func (d *Discord) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(DiscordMessage(msg))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	return post(ctx, d.httpClient, "discord", d.url, nil, body)
+}
+
+// DiscordMessage converts msg to Discord payload with an embed
+func DiscordMessage(msg Message) *DiscordPayload {
+	if msg.Title == "" && msg.URL == "" && msg.Color == "" && len(msg.Fields) == 0 {
+		return &DiscordPayload{Content: msg.Text}
+	}
+	e := DiscordEmbed{
+		Title:       msg.Title,
+		Description: msg.Text,
+		URL:         msg.URL,
+		Color:       parseColor(msg.Color),
+	}
+	for _, f := range msg.Fields {
+		e.Fields = append(e.Fields, DiscordEmbedField{Name: f.Name, Value: f.Value, Inline: f.Short})
+	}
+	return &DiscordPayload{Embeds: []DiscordEmbed{e}}
+}
+
+// parseColor gives integer of hex color such as "#d50200", or 0 when it's not hex
+func parseColor(color string) int {
+	c, err := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(c)
+}
@@ -0,0 +1,10 @@
+module github.com/fckey/go-sandbox/notifyop
+
+go 1.14
+
+require github.com/fckey/go-sandbox/slackop v0.0.0-00010101000000-000000000000
+
+replace (
+	github.com/fckey/go-sandbox/retry => ../retry
+	github.com/fckey/go-sandbox/slackop => ../slackop
+)
@@ -0,0 +1,141 @@
+package notifyop
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxErrorBodySize is size of the response body kept in StatusError
+const maxErrorBodySize = 1024
+
+// Message is notification independent from the backends
+type Message struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+	// URL is link to the detail of the notification
+	URL string `json:"url,omitempty"`
+	// Color is hex color such as "#d50200" used by backends supporting it
+	Color  string  `json:"color,omitempty"`
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// Field is a name and value shown in the notification
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Short lays the field side by side when the backend supports it
+	Short bool `json:"short,omitempty"`
+}
+
+// Notifier sends Message to a backend
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NotifierFunc is an adapter of function to Notifier
+type NotifierFunc func(ctx context.Context, msg Message) error
+
+// Notify calls f(ctx, msg)
+func (f NotifierFunc) Notify(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// StatusError is non-2xx response of HTTP based backend
+type StatusError struct {
+	Backend    string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded status %d: %s", e.Backend, e.StatusCode, e.Body)
+}
+
+// FanOutError has errors of the backends which failed keyed by the name
+type FanOutError struct {
+	Errors map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e.Errors[name].Error())
+	}
+	return "failed to notify " + strings.Join(msgs, "; ")
+}
+
+// FanOut sends Message to every backend concurrently
+type FanOut struct {
+	notifiers map[string]Notifier
+}
+
+// NewFanOut gives FanOut of notifiers keyed by name such as "slack" or "email"
+func NewFanOut(notifiers map[string]Notifier) *FanOut {
+	return &FanOut{notifiers: notifiers}
+}
+
+// Notify sends msg to every backend and waits for them.
+// Error is *FanOutError when any of them failed.
+func (f *FanOut) Notify(ctx context.Context, msg Message) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := map[string]error{}
+	for name, n := range f.notifiers {
+		wg.Add(1)
+		go func(name string, n Notifier) {
+			defer wg.Done()
+			if err := n.Notify(ctx, msg); err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}(name, n)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &FanOutError{Errors: errs}
+	}
+	return nil
+}
+
+// post sends body to url and checks the status
+func post(ctx context.Context, c *http.Client, backend, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &StatusError{Backend: backend, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+}
@@ -0,0 +1,203 @@
+package notifyop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fckey/go-sandbox/slackop"
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+var testMessage = Message{
+	Title:  "Build failed",
+	Text:   "go-sandbox master is broken",
+	URL:    "https://ci.example.com/builds/1",
+	Color:  "#d50200",
+	Fields: []Field{{Name: "Branch", Value: "master", Short: true}},
+}
+
+// recorder is httptest server recording request bodies
+type recorder struct {
+	*httptest.Server
+	bodies  [][]byte
+	headers []http.Header
+}
+
+func newRecorder(t *testing.T, status int) *recorder {
+	t.Helper()
+	rec := &recorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		rec.bodies = append(rec.bodies, b)
+		rec.headers = append(rec.headers, r.Header)
+		w.WriteHeader(status)
+		if status >= 300 {
+			w.Write([]byte("boom"))
+		}
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *recorder) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if len(rec.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(rec.bodies))
+	}
+	if err := json.Unmarshal(rec.bodies[0], v); err != nil {
+		t.Fatalf("invalid body %s: %v", rec.bodies[0], err)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	s := slacktest.NewServer()
+	defer s.Close()
+	n := NewSlack(slackop.NewManager(s.WebhookURL()))
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	var got slackop.Message
+	if err := s.RequestsOf(slacktest.MethodWebhook)[0].Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := slackop.Message{Attachments: []slackop.Attachment{{
+		Color:     "#d50200",
+		Fallback:  "Build failed: go-sandbox master is broken",
+		Title:     "Build failed",
+		TitleLink: "https://ci.example.com/builds/1",
+		Text:      "go-sandbox master is broken",
+		Fields:    []slackop.AttachmentField{{Title: "Branch", Value: "master", Short: true}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSlack_Error(t *testing.T) {
+	s := slacktest.NewServer()
+	defer s.Close()
+	s.Inject(slacktest.MethodWebhook, slacktest.SlackError("channel_not_found"))
+	err := NewSlack(slackop.NewManager(s.WebhookURL())).Notify(context.Background(), testMessage)
+	if !errors.Is(err, slackop.ErrChannelNotFound) {
+		t.Errorf("got %v, want ErrChannelNotFound", err)
+	}
+}
+
+func TestSlackMessage_Text(t *testing.T) {
+	got := SlackMessage(Message{Text: "hello"})
+	if got.Text != "hello" || len(got.Attachments) != 0 {
+		t.Errorf("got %+v, want plain text", got)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	rec := newRecorder(t, http.StatusAccepted)
+	if err := NewTeams(rec.URL, nil).Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type string `json:"type"`
+				Body []struct {
+					Type  string `json:"type"`
+					Text  string `json:"text"`
+					Facts []struct {
+						Title string `json:"title"`
+						Value string `json:"value"`
+					} `json:"facts"`
+				} `json:"body"`
+				Actions []struct {
+					URL string `json:"url"`
+				} `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	rec.decode(t, &got)
+	if got.Type != "message" || len(got.Attachments) != 1 {
+		t.Fatalf("got %+v, want a message with an attachment", got)
+	}
+	card := got.Attachments[0]
+	if card.ContentType != adaptiveCardContentType || card.Content.Type != "AdaptiveCard" {
+		t.Errorf("got %s %s, want Adaptive Card", card.ContentType, card.Content.Type)
+	}
+	body := card.Content.Body
+	if len(body) != 3 || body[0].Text != "Build failed" || body[1].Text != "go-sandbox master is broken" ||
+		len(body[2].Facts) != 1 || body[2].Facts[0].Title != "Branch" {
+		t.Errorf("got body %+v", body)
+	}
+	if len(card.Content.Actions) != 1 || card.Content.Actions[0].URL != testMessage.URL {
+		t.Errorf("got actions %+v, want link to %s", card.Content.Actions, testMessage.URL)
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	rec := newRecorder(t, http.StatusNoContent)
+	if err := NewDiscord(rec.URL, nil).Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	var got DiscordPayload
+	rec.decode(t, &got)
+	want := DiscordPayload{Embeds: []DiscordEmbed{{
+		Title:       "Build failed",
+		Description: "go-sandbox master is broken",
+		URL:         "https://ci.example.com/builds/1",
+		Color:       0xd50200,
+		Fields:      []DiscordEmbedField{{Name: "Branch", Value: "master", Inline: true}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDiscord_Error(t *testing.T) {
+	rec := newRecorder(t, http.StatusBadRequest)
+	err := NewDiscord(rec.URL, nil).Notify(context.Background(), Message{Text: "hello"})
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusBadRequest || serr.Body != "boom" {
+		t.Fatalf("got %v, want StatusError of 400", err)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	ok := newRecorder(t, http.StatusNoContent)
+	ng := newRecorder(t, http.StatusInternalServerError)
+	f := NewFanOut(map[string]Notifier{
+		"discord": NewDiscord(ok.URL, nil),
+		"teams":   NewTeams(ng.URL, nil),
+		"func": NotifierFunc(func(ctx context.Context, msg Message) error {
+			return errors.New("unavailable")
+		}),
+	})
+	err := f.Notify(context.Background(), testMessage)
+	var ferr *FanOutError
+	if !errors.As(err, &ferr) {
+		t.Fatalf("got %v, want FanOutError", err)
+	}
+	if len(ferr.Errors) != 2 || ferr.Errors["teams"] == nil || ferr.Errors["func"] == nil {
+		t.Errorf("got %v, want errors of teams and func", ferr.Errors)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to notify func: unavailable; teams: ") {
+		t.Errorf("got %q", err.Error())
+	}
+	if len(ok.bodies) != 1 || len(ng.bodies) != 1 {
+		t.Errorf("got %d and %d requests, want 1 each", len(ok.bodies), len(ng.bodies))
+	}
+}
+
+func TestFanOut_OK(t *testing.T) {
+	rec := newRecorder(t, http.StatusNoContent)
+	f := NewFanOut(map[string]Notifier{"discord": NewDiscord(rec.URL, nil)})
+	if err := f.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+}
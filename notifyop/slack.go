@@ -0,0 +1,51 @@
+package notifyop
+
+import (
+	"context"
+
+	"github.com/fckey/go-sandbox/slackop"
+)
+
+// Slack notifies to Slack incoming webhook through slackop.Manager
+type Slack struct {
+	mgr *slackop.Manager
+}
+
+// NewSlack gives Slack sending by mgr
+func NewSlack(mgr *slackop.Manager) *Slack {
+	return &Slack{mgr: mgr}
+}
+
+// Notify implements Notifier
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	return s.mgr.NotifyContext(ctx, SlackMessage(msg))
+}
+
+// SlackMessage converts msg to Slack message with an attachment
+func SlackMessage(msg Message) *slackop.Message {
+	if msg.Title == "" && msg.URL == "" && msg.Color == "" && len(msg.Fields) == 0 {
+		return &slackop.Message{Text: msg.Text}
+	}
+	a := slackop.Attachment{
+		Color:     msg.Color,
+		Fallback:  fallback(msg),
+		Title:     msg.Title,
+		TitleLink: msg.URL,
+		Text:      msg.Text,
+	}
+	for _, f := range msg.Fields {
+		a.Fields = append(a.Fields, slackop.AttachmentField{Title: f.Name, Value: f.Value, Short: f.Short})
+	}
+	return &slackop.Message{Attachments: []slackop.Attachment{a}}
+}
+
+// fallback gives plain text summary of msg
+func fallback(msg Message) string {
+	switch {
+	case msg.Title == "":
+		return msg.Text
+	case msg.Text == "":
+		return msg.Title
+	}
+	return msg.Title + ": " + msg.Text
+}
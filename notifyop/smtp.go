@@ -0,0 +1,148 @@
+package notifyop
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends Message as plain text email
+type SMTP struct {
+	addr      string
+	from      string
+	to        []string
+	auth      smtp.Auth
+	tlsConfig *tls.Config
+	now       func() time.Time
+}
+
+// SMTPOption configures SMTP
+type SMTPOption func(*SMTP)
+
+// WithSMTPAuth authenticates by a such as smtp.PlainAuth
+func WithSMTPAuth(a smtp.Auth) SMTPOption {
+	return func(s *SMTP) {
+		s.auth = a
+	}
+}
+
+// WithSMTPTLSConfig sets tls.Config of STARTTLS.
+// ServerName is host of the address by default.
+func WithSMTPTLSConfig(c *tls.Config) SMTPOption {
+	return func(s *SMTP) {
+		s.tlsConfig = c
+	}
+}
+
+// NewSMTP gives SMTP sending from address from to addresses to via server addr such as "smtp.example.com:587"
+func NewSMTP(addr, from string, to []string, opts ...SMTPOption) *SMTP {
+	s := &SMTP{addr: addr, from: from, to: to, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Notify implements Notifier.
+// STARTTLS is used when the server supports it.
+func (s *SMTP) Notify(ctx context.Context, msg Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		cfg := s.tlsConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return fmt.Errorf("failed to start TLS: %v", err)
+		}
+	}
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.mail(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// mail gives RFC 5322 message of msg
+func (s *SMTP) mail(msg Message) []byte {
+	subject := msg.Title
+	if subject == "" {
+		subject = firstLine(msg.Text)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", s.now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+
+	var body []string
+	if msg.Text != "" {
+		body = append(body, msg.Text, "")
+	}
+	for _, f := range msg.Fields {
+		body = append(body, f.Name+": "+f.Value)
+	}
+	if msg.URL != "" {
+		if len(msg.Fields) > 0 {
+			body = append(body, "")
+		}
+		body = append(body, msg.URL)
+	}
+	b.WriteString(strings.Join(strings.Split(strings.Join(body, "\n"), "\n"), "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
@@ -0,0 +1,133 @@
+package notifyop
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is minimal SMTP server accepting one mail
+type fakeSMTP struct {
+	addr     string
+	from     string
+	rcpts    []string
+	data     string
+	received chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &fakeSMTP{addr: l.Addr().String(), received: make(chan struct{})}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(textproto.NewConn(conn))
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(c *textproto.Conn) {
+	defer close(s.received)
+	c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			s.from = strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">"))
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 Go ahead")
+			b, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(b)
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	srv := newFakeSMTP(t)
+	n := NewSMTP(srv.addr, "bot@example.com", []string{"a@example.com", "b@example.com"})
+	n.now = func() time.Time { return time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	<-srv.received
+
+	if srv.from != "bot@example.com" {
+		t.Errorf("got from %q", srv.from)
+	}
+	if strings.Join(srv.rcpts, ",") != "a@example.com,b@example.com" {
+		t.Errorf("got recipients %v", srv.rcpts)
+	}
+	// ReadDotBytes converts CRLF to LF
+	want := strings.Join([]string{
+		"From: bot@example.com",
+		"To: a@example.com, b@example.com",
+		"Subject: Build failed",
+		"Date: Fri, 01 May 2020 09:00:00 +0000",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+		"",
+		"go-sandbox master is broken",
+		"",
+		"Branch: master",
+		"",
+		"https://ci.example.com/builds/1",
+		"",
+	}, "\n")
+	if srv.data != want {
+		t.Errorf("got mail\n%s\nwant\n%s", srv.data, want)
+	}
+}
+
+func TestSMTP_EncodedSubject(t *testing.T) {
+	n := NewSMTP("localhost:25", "bot@example.com", []string{"a@example.com"})
+	mail := string(n.mail(Message{Text: "ビルド失敗\ndetail"}))
+	if !strings.Contains(mail, "Subject: =?utf-8?q?") {
+		t.Errorf("got mail without encoded subject\n%s", mail)
+	}
+}
+
+func TestSMTP_Unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if err := NewSMTP(addr, "bot@example.com", nil).Notify(context.Background(), testMessage); err == nil {
+		t.Error("got no error for closed server")
+	}
+}
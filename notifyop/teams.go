@@ -0,0 +1,70 @@
+package notifyop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Content type and version of Adaptive Card posted to Teams
+const (
+	adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.4"
+)
+
+// Teams posts Message as Adaptive Card to Microsoft Teams webhook
+type Teams struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewTeams gives Teams posting to webhook url of Teams workflow or connector
+func NewTeams(url string, c *http.Client) *Teams {
+	return &Teams{url: url, httpClient: c}
+}
+
+// Notify implements Notifier
+func (t *Teams) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(TeamsCard(msg))
+	if err != nil {
+		return fmt.Errorf("failed to marshal card: %v", err)
+	}
+	return post(ctx, t.httpClient, "teams", t.url, nil, body)
+}
+
+// TeamsCard converts msg to Teams message with an Adaptive Card
+func TeamsCard(msg Message) map[string]interface{} {
+	var body []map[string]interface{}
+	if msg.Title != "" {
+		body = append(body, map[string]interface{}{
+			"type": "TextBlock", "text": msg.Title, "size": "Large", "weight": "Bolder", "wrap": true,
+		})
+	}
+	if msg.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": msg.Text, "wrap": true})
+	}
+	if len(msg.Fields) > 0 {
+		facts := make([]map[string]string, 0, len(msg.Fields))
+		for _, f := range msg.Fields {
+			facts = append(facts, map[string]string{"title": f.Name, "value": f.Value})
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"$schema": adaptiveCardSchema,
+		"version": adaptiveCardVersion,
+		"body":    body,
+	}
+	if msg.URL != "" {
+		card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": "Open", "url": msg.URL}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{"contentType": adaptiveCardContentType, "content": card},
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package notifyop
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// DefaultSignatureHeader is header of HMAC signature set by Webhook
+const DefaultSignatureHeader = "X-Signature-256"
+
+// signaturePrefix is prefix of the signature such as "sha256=..."
+const signaturePrefix = "sha256="
+
+// Webhook posts Message as JSON to arbitrary endpoint.
+// Body is JSON of Message by default, or rendered by template given by WithBodyTemplate.
+type Webhook struct {
+	url             string
+	httpClient      *http.Client
+	header          http.Header
+	body            *template.Template
+	secret          []byte
+	signatureHeader string
+}
+
+// WebhookOption configures Webhook
+type WebhookOption func(*Webhook) error
+
+// WithBodyTemplate renders request body by text/template with Message.
+// Function "json" encodes value as JSON literal such as {"text": {{json .Text}}}.
+func WithBodyTemplate(text string) WebhookOption {
+	return func(w *Webhook) error {
+		t, err := template.New("body").Funcs(template.FuncMap{"json": toJSON}).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("failed to parse body template: %v", err)
+		}
+		w.body = t
+		return nil
+	}
+}
+
+// WithHeader adds header to the request such as Authorization
+func WithHeader(key, value string) WebhookOption {
+	return func(w *Webhook) error {
+		w.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithHMACSecret signs the body by HMAC-SHA256 of secret.
+// Signature is set to DefaultSignatureHeader as "sha256=<hex>".
+func WithHMACSecret(secret []byte) WebhookOption {
+	return func(w *Webhook) error {
+		w.secret = secret
+		return nil
+	}
+}
+
+// WithSignatureHeader sets header of the signature instead of DefaultSignatureHeader
+func WithSignatureHeader(name string) WebhookOption {
+	return func(w *Webhook) error {
+		w.signatureHeader = name
+		return nil
+	}
+}
+
+// WithWebhookHTTPClient sets http.Client calling the webhook
+func WithWebhookHTTPClient(c *http.Client) WebhookOption {
+	return func(w *Webhook) error {
+		w.httpClient = c
+		return nil
+	}
+}
+
+// NewWebhook gives Webhook posting to url
+func NewWebhook(url string, opts ...WebhookOption) (*Webhook, error) {
+	w := &Webhook{
+		url:             url,
+		header:          http.Header{},
+		signatureHeader: DefaultSignatureHeader,
+	}
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	body, err := w.render(msg)
+	if err != nil {
+		return err
+	}
+	header := w.header.Clone()
+	if w.secret != nil {
+		header.Set(w.signatureHeader, SignBody(w.secret, body))
+	}
+	return post(ctx, w.httpClient, "webhook", w.url, header, body)
+}
+
+func (w *Webhook) render(msg Message) ([]byte, error) {
+	if w.body == nil {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal msg: %v", err)
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if err := w.body.Execute(&buf, msg); err != nil {
+		return nil, fmt.Errorf("failed to render body: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SignBody gives "sha256=<hex>" of HMAC-SHA256 of body so that the receiver can verify it
+func SignBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// toJSON encodes v as JSON literal
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,64 @@
+package notifyop
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	rec := newRecorder(t, http.StatusOK)
+	w, err := NewWebhook(rec.URL, WithHeader("Authorization", "Bearer token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Notify(context.Background(), Message{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(rec.bodies[0]), `{"text":"hello"}`; got != want {
+		t.Errorf("got body %s, want %s", got, want)
+	}
+	if got := rec.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Errorf("got Authorization %q", got)
+	}
+	if got := rec.headers[0].Get(DefaultSignatureHeader); got != "" {
+		t.Errorf("got signature %q without secret", got)
+	}
+}
+
+func TestWebhook_TemplateAndSignature(t *testing.T) {
+	rec := newRecorder(t, http.StatusOK)
+	w, err := NewWebhook(rec.URL,
+		WithBodyTemplate(`{"summary": {{json .Title}}, "detail": {{json .Text}}}`),
+		WithHMACSecret([]byte("secret")),
+		WithSignatureHeader("X-Hub-Signature-256"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Notify(context.Background(), Message{Title: "Build \"failed\"", Text: "see log"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"summary": "Build \"failed\"", "detail": "see log"}`
+	if got := string(rec.bodies[0]); got != want {
+		t.Errorf("got body %s, want %s", got, want)
+	}
+	if got, want := rec.headers[0].Get("X-Hub-Signature-256"), SignBody([]byte("secret"), []byte(want)); got != want {
+		t.Errorf("got signature %q, want %q", got, want)
+	}
+}
+
+func TestWebhook_InvalidTemplate(t *testing.T) {
+	if _, err := NewWebhook("http://localhost", WithBodyTemplate("{{.Title")); err == nil {
+		t.Error("got no error for broken template")
+	}
+}
+
+func TestSignBody(t *testing.T) {
+	// Example of GitHub webhook document
+	got := SignBody([]byte("It's a Secret to Everybody"), []byte("Hello, World!"))
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
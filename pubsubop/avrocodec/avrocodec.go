@@ -0,0 +1,38 @@
+// Package avrocodec provides pubsubop.Codec encoding values in Avro binary format.
+package avrocodec
+
+import (
+	"github.com/hamba/avro/v2"
+)
+
+// ContentType is content type of the codec
+const ContentType = "avro/binary"
+
+// Codec encodes values by the Avro schema
+type Codec struct {
+	schema avro.Schema
+}
+
+// New parses the schema and creates Codec of it
+func New(schema string) (*Codec, error) {
+	s, err := avro.Parse(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{schema: s}, nil
+}
+
+// ContentType gives ContentType
+func (c *Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal encodes v by the schema
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	return avro.Marshal(c.schema, v)
+}
+
+// Unmarshal decodes data into v by the schema
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return avro.Unmarshal(c.schema, data, v)
+}
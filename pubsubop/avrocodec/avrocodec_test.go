@@ -0,0 +1,47 @@
+package avrocodec
+
+import (
+	"testing"
+
+	"github.com/fckey/go-sandbox/pubsubop"
+)
+
+const tweetSchema = `{
+	"type": "record",
+	"name": "Tweet",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "text", "type": "string"}
+	]
+}`
+
+type tweet struct {
+	ID   int64  `avro:"id"`
+	Text string `avro:"text"`
+}
+
+var _ pubsubop.Codec = (*Codec)(nil)
+
+func TestCodec(t *testing.T) {
+	c, err := New(tweetSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Marshal(tweet{ID: 1, Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got tweet
+	if err := c.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (tweet{ID: 1, Text: "hello"}) {
+		t.Errorf("got %+v", got)
+	}
+	if err := c.Unmarshal([]byte{0x02, 0x0a, 'h'}, &got); err == nil {
+		t.Error("got nil, want error of truncated data")
+	}
+	if _, err := New(`{"type": "record"}`); err == nil {
+		t.Error("got nil, want error of invalid schema")
+	}
+}
@@ -0,0 +1,126 @@
+// Package boltdedup provides pubsubop.DedupStore backed by embedded key-value store bbolt.
+package boltdedup
+
+import (
+	"time"
+
+	"github.com/fckey/go-sandbox/pubsubop"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketKeys maps key to time when it was marked
+var bucketKeys = []byte("keys")
+
+// Store is pubsubop.DedupStore of bbolt database
+type Store struct {
+	db  *bolt.DB
+	ttl time.Duration
+	now func() time.Time
+
+	// nextPrune is guarded by the writable transaction of db
+	nextPrune time.Time
+}
+
+// Option configures Store
+type Option func(*Store)
+
+// WithTTL sets how long keys are remembered
+func WithTTL(d time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = d
+	}
+}
+
+// Open opens or creates database at path.
+// Keys marked before the TTL are removed on open and by Mark once per TTL.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{ttl: pubsubop.DefaultDedupTTL, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// init creates the bucket and removes expired keys
+func (s *Store) init() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketKeys); err != nil {
+			return err
+		}
+		return s.prune(tx)
+	})
+}
+
+// prune removes expired keys
+func (s *Store) prune(tx *bolt.Tx) error {
+	s.nextPrune = s.now().Add(s.ttl)
+	b := tx.Bucket(bucketKeys)
+	// Deleting with cursor while iterating skips the next key
+	var expired [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if s.expired(v) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Seen implements pubsubop.DedupStore
+func (s *Store) Seen(key string) (bool, error) {
+	seen := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketKeys).Get([]byte(key))
+		seen = v != nil && !s.expired(v)
+		return nil
+	})
+	return seen, err
+}
+
+// Mark implements pubsubop.DedupStore
+func (s *Store) Mark(key string) error {
+	now := s.now()
+	t, err := now.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketKeys).Put([]byte(key), t); err != nil {
+			return err
+		}
+		if now.After(s.nextPrune) {
+			return s.prune(tx)
+		}
+		return nil
+	})
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// expired reports whether time v of the key is before the TTL
+func (s *Store) expired(v []byte) bool {
+	var t time.Time
+	if err := t.UnmarshalBinary(v); err != nil {
+		return true
+	}
+	return !s.now().Before(t.Add(s.ttl))
+}
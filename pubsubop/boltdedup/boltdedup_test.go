@@ -0,0 +1,116 @@
+package boltdedup
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/fckey/go-sandbox/pubsubop"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Mark("1"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen, err := s.Seen("1"); !seen || err != nil {
+		t.Errorf("got %v %v, want seen after reopen", seen, err)
+	}
+	if seen, _ := s.Seen("2"); seen {
+		t.Error("2 is seen")
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now.Add(pubsubop.DefaultDedupTTL) }
+	if seen, _ := s.Seen("1"); seen {
+		t.Error("1 is seen after TTL")
+	}
+	s.Close()
+}
+
+func TestStore_TTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Mark("1")
+	s.Close()
+
+	s, err = Open(path, WithTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = Open(path, WithTTL(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.ttl = time.Hour
+	if seen, _ := s.Seen("1"); seen {
+		t.Error("key marked before TTL was not removed on open")
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "dedup.db"), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"1", "2"} {
+		if err := s.Mark(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Keys expired are removed by Mark after the TTL even if they are not read again
+	now = now.Add(2 * time.Hour)
+	if err := s.Mark("3"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketKeys).Stats().KeyN
+		return nil
+	})
+	if n != 1 {
+		t.Errorf("got %d keys, want %d", n, 1)
+	}
+}
+
+func TestStore_Dedup(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "dedup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	calls := 0
+	h := pubsubop.Dedup(func(ctx context.Context, msg *pubsub.Message) pubsubop.Decision {
+		calls++
+		return pubsubop.Ack
+	}, s, pubsubop.DedupByMessageID)
+	for i := 0; i < 2; i++ {
+		if d := h(context.Background(), &pubsub.Message{ID: "1"}); !d.IsAck() {
+			t.Errorf("got %v, want ack", d)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
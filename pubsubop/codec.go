@@ -0,0 +1,81 @@
+package pubsubop
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentTypeAttribute is attribute of message telling encoding of the data
+const ContentTypeAttribute = "content-type"
+
+// Content types of codecs given by pubsubop
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+// Codec encodes values into data of message
+type Codec interface {
+	// ContentType is set to ContentTypeAttribute of the message
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v which is pointer
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values by encoding/json
+type JSONCodec struct{}
+
+// ContentType gives ContentTypeJSON
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec encodes proto.Message in binary wire format
+type ProtoCodec struct{}
+
+// ContentType gives ContentTypeProtobuf
+func (ProtoCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// Marshal encodes v which is proto.Message
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v which is proto.Message or pointer to it.
+// Pointer to nil message is set to new message.
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+			return fmt.Errorf("%T is not proto.Message", v)
+		}
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		if m, ok = rv.Elem().Interface().(proto.Message); !ok {
+			return fmt.Errorf("%T is not proto.Message", v)
+		}
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,164 @@
+package pubsubop
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Decision tells Consume how to settle the message given to Handler
+type Decision struct {
+	nack  bool
+	delay time.Duration
+}
+
+var (
+	// Ack acknowledges the message so that it isn't delivered again
+	Ack = Decision{}
+	// Nack asks Pub/Sub to redeliver the message immediately
+	Nack = Decision{nack: true}
+)
+
+// NackWithDelay asks Pub/Sub to redeliver the message after d.
+// The message is held until the delay passes, so it keeps its slot of flow control meanwhile.
+func NackWithDelay(d time.Duration) Decision {
+	return Decision{nack: true, delay: d}
+}
+
+// IsAck reports whether the message is acknowledged
+func (d Decision) IsAck() bool {
+	return !d.nack
+}
+
+// Delay gives the delay before the message is nacked
+func (d Decision) Delay() time.Duration {
+	return d.delay
+}
+
+// Handler processes a message received by Consume
+type Handler func(ctx context.Context, msg *pubsub.Message) Decision
+
+// ConsumeOption configures Consume
+type ConsumeOption func(*consumer)
+
+type consumer struct {
+	concurrency int
+	settings    pubsub.ReceiveSettings
+	deadLetter  DeadLetterFunc
+	dedup       func(Handler) Handler
+}
+
+// WithConcurrency limits the number of handlers running at the same time.
+// Zero or negative means no limit other than the flow control.
+func WithConcurrency(n int) ConsumeOption {
+	return func(c *consumer) {
+		c.concurrency = n
+	}
+}
+
+// WithMaxOutstandingMessages sets the number of messages received but not yet settled
+func WithMaxOutstandingMessages(n int) ConsumeOption {
+	return func(c *consumer) {
+		c.settings.MaxOutstandingMessages = n
+	}
+}
+
+// WithMaxOutstandingBytes sets the size of messages received but not yet settled
+func WithMaxOutstandingBytes(n int) ConsumeOption {
+	return func(c *consumer) {
+		c.settings.MaxOutstandingBytes = n
+	}
+}
+
+// WithMaxExtension sets the maximum period for which the ack deadline of a message is extended
+func WithMaxExtension(d time.Duration) ConsumeOption {
+	return func(c *consumer) {
+		c.settings.MaxExtension = d
+	}
+}
+
+// WithDeadLetterHandler gives messages failing validation of WithSchemaRegistry to f instead of nacking them
+func WithDeadLetterHandler(f DeadLetterFunc) ConsumeOption {
+	return func(c *consumer) {
+		c.deadLetter = f
+	}
+}
+
+// Consume receives messages of the subscription and settles them by the decision of h
+// until ctx is cancelled. On cancellation it stops pulling, waits for the handlers in flight
+// to return and their decisions to be sent, then returns nil.
+// The context given to h is cancelled on shutdown, and messages waiting for NackWithDelay are nacked then.
+func (mgr *Manager) Consume(ctx context.Context, h Handler, opts ...ConsumeOption) error {
+	c := &consumer{settings: pubsub.DefaultReceiveSettings}
+	for _, opt := range opts {
+		opt(c)
+	}
+	sub := mgr.client.Subscription(mgr.SubName)
+	sub.ReceiveSettings = c.settings
+
+	handle := func(ctx context.Context, msg *pubsub.Message) Decision {
+		if err := mgr.validateReceived(msg); err != nil {
+			return deadLetter(ctx, c.deadLetter, msg, err)
+		}
+		return h(ctx, msg)
+	}
+	if c.dedup != nil {
+		handle = c.dedup(handle)
+	}
+
+	var sem chan struct{}
+	if c.concurrency > 0 {
+		sem = make(chan struct{}, c.concurrency)
+	}
+	return sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				msg.Nack()
+				return
+			}
+		}
+		d := handle(ctx, msg)
+		if sem != nil {
+			<-sem
+		}
+		settle(ctx, msg, d)
+	})
+}
+
+// validateReceived checks msg by the schema of the topic when WithSchemaRegistry is given
+func (mgr *Manager) validateReceived(msg *pubsub.Message) error {
+	if mgr.schemas == nil {
+		return nil
+	}
+	_, err := mgr.schemas.Validate(mgr.TopicName, msg.Attributes[SchemaVersionAttribute],
+		msg.Attributes[ContentTypeAttribute], msg.Data)
+	return err
+}
+
+// deadLetter gives msg to f, and acks it when f succeeds
+func deadLetter(ctx context.Context, f DeadLetterFunc, msg *pubsub.Message, err error) Decision {
+	if f == nil || f(ctx, msg, err) != nil {
+		return Nack
+	}
+	return Ack
+}
+
+// settle acks or nacks msg by d
+func settle(ctx context.Context, msg *pubsub.Message, d Decision) {
+	if d.IsAck() {
+		msg.Ack()
+		return
+	}
+	if d.delay > 0 {
+		t := time.NewTimer(d.delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+	}
+	msg.Nack()
+}
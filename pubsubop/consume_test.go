@@ -0,0 +1,118 @@
+package pubsubop
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestManager_Consume(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	for _, data := range []string{"a", "b", "c"} {
+		srv.Publish(testTopic, []byte(data), nil)
+	}
+
+	var mu sync.Mutex
+	got := map[string]int{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mgr.Consume(ctx, func(ctx context.Context, msg *pubsub.Message) Decision {
+			mu.Lock()
+			defer mu.Unlock()
+			got[string(msg.Data)]++
+			if string(msg.Data) == "b" && got["b"] == 1 {
+				return Nack
+			}
+			if string(msg.Data) == "c" {
+				return NackWithDelay(time.Hour)
+			}
+			return Ack
+		}, WithConcurrency(2), WithMaxOutstandingMessages(10), func(c *consumer) {
+			// Receipt modack of the client sent after the nack extends the deadline again,
+			// so the deadline is kept short for the nacked message to be redelivered soon.
+			c.settings.MinExtensionPeriod, c.settings.MaxExtensionPeriod = time.Second, time.Second
+		})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := got["a"] + got["b"] + got["c"]
+		mu.Unlock()
+		if n >= 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Consume didn't return on cancel")
+	}
+
+	if got["a"] != 1 || got["b"] != 2 {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+	acked := map[string]bool{}
+	for _, m := range srv.Messages() {
+		acked[string(m.Data)] = m.Acks > 0
+	}
+	if !acked["a"] || !acked["b"] || acked["c"] {
+		t.Errorf("unexpected acks: %v", acked)
+	}
+}
+
+func TestManager_Consume_WaitsInFlight(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	srv.Publish(testTopic, []byte("slow"), nil)
+
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := false
+	done := make(chan error)
+	go func() {
+		done <- mgr.Consume(ctx, func(hctx context.Context, msg *pubsub.Message) Decision {
+			close(started)
+			<-hctx.Done()
+			time.Sleep(50 * time.Millisecond)
+			finished = true
+			return Ack
+		})
+	}()
+	<-started
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !finished {
+		t.Error("Consume returned before the handler finished")
+	}
+	if m := srv.Messages()[0]; m.Acks != 1 {
+		t.Errorf("acks: got %d, want %d", m.Acks, 1)
+	}
+}
+
+func TestManager_PullMessages(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	for _, data := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		srv.Publish(testTopic, []byte(data), nil)
+	}
+	got, err := mgr.PullMessages()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 5 {
+		t.Errorf("got %d messages, want %d", len(got), 5)
+	}
+}
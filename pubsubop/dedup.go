@@ -0,0 +1,197 @@
+package pubsubop
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// DefaultDedupTTL is how long keys of processed messages are remembered
+const DefaultDedupTTL = 24 * time.Hour
+
+// dedupInFlightDelay is delay of nack of message whose key is being processed by another handler
+const dedupInFlightDelay = 5 * time.Second
+
+// DedupStore remembers keys of processed messages
+type DedupStore interface {
+	// Seen reports whether key was marked and is not expired
+	Seen(key string) (bool, error)
+	// Mark remembers key of message processed
+	Mark(key string) error
+}
+
+// DedupKeyFunc gives key identifying message. Message of empty key is not deduplicated.
+type DedupKeyFunc func(msg *pubsub.Message) string
+
+// DedupByMessageID keys message by ID given by Pub/Sub.
+// It drops redelivery of the message but not the same data published twice.
+func DedupByMessageID(msg *pubsub.Message) string {
+	return msg.ID
+}
+
+// DedupByAttribute keys message by the attribute set by publisher such as tweet ID
+func DedupByAttribute(name string) DedupKeyFunc {
+	return func(msg *pubsub.Message) string {
+		return msg.Attributes[name]
+	}
+}
+
+// DedupByPayload keys message by SHA-256 of the data
+func DedupByPayload(msg *pubsub.Message) string {
+	sum := sha256.Sum256(msg.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Dedup wraps h to ack messages whose key was already processed without calling h.
+// Key is marked when h acks the message. Message whose key is being processed is nacked
+// with delay, and message is nacked when store fails to tell whether it was processed.
+// Failure of marking is ignored, so the message can be processed again on redelivery.
+func Dedup(h Handler, store DedupStore, key DedupKeyFunc) Handler {
+	var mu sync.Mutex
+	inflight := map[string]bool{}
+	return func(ctx context.Context, msg *pubsub.Message) Decision {
+		k := key(msg)
+		if k == "" {
+			return h(ctx, msg)
+		}
+		mu.Lock()
+		if inflight[k] {
+			mu.Unlock()
+			return NackWithDelay(dedupInFlightDelay)
+		}
+		inflight[k] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(inflight, k)
+			mu.Unlock()
+		}()
+
+		seen, err := store.Seen(k)
+		if err != nil {
+			return Nack
+		}
+		if seen {
+			return Ack
+		}
+		d := h(ctx, msg)
+		if d.IsAck() {
+			store.Mark(k)
+		}
+		return d
+	}
+}
+
+// WithDedup skips messages of the key already processed by Dedup
+func WithDedup(store DedupStore, key DedupKeyFunc) ConsumeOption {
+	return func(c *consumer) {
+		c.dedup = func(h Handler) Handler {
+			return Dedup(h, store, key)
+		}
+	}
+}
+
+// DedupStoreOption configures MemoryDedupStore and FileDedupStore
+type DedupStoreOption func(*dedupOptions)
+
+type dedupOptions struct {
+	ttl time.Duration
+}
+
+// WithDedupTTL sets how long keys are remembered
+func WithDedupTTL(d time.Duration) DedupStoreOption {
+	return func(o *dedupOptions) {
+		o.ttl = d
+	}
+}
+
+func newDedupOptions(opts []DedupStoreOption) dedupOptions {
+	o := dedupOptions{ttl: DefaultDedupTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// MemoryDedupStore is DedupStore in memory which forgets least recently used keys over the capacity
+type MemoryDedupStore struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+	// order has *dedupEntry from most recently used
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key    string
+	marked time.Time
+}
+
+// NewMemoryDedupStore creates empty MemoryDedupStore keeping up to capacity keys.
+// Zero or negative capacity means no limit other than the TTL.
+func NewMemoryDedupStore(capacity int, opts ...DedupStoreOption) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		ttl:      newDedupOptions(opts).ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Seen implements DedupStore
+func (m *MemoryDedupStore) Seen(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return false, nil
+	}
+	if m.expired(el) {
+		m.remove(el)
+		return false, nil
+	}
+	m.order.MoveToFront(el)
+	return true, nil
+}
+
+// Mark implements DedupStore
+func (m *MemoryDedupStore) Mark(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*dedupEntry).marked = now
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&dedupEntry{key: key, marked: now})
+	for el := m.order.Back(); el != nil && (m.capacity > 0 && m.order.Len() > m.capacity || m.expired(el)); el = m.order.Back() {
+		m.remove(el)
+	}
+	return nil
+}
+
+// Len gives the number of keys kept
+func (m *MemoryDedupStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryDedupStore) expired(el *list.Element) bool {
+	return !m.now().Before(el.Value.(*dedupEntry).marked.Add(m.ttl))
+}
+
+func (m *MemoryDedupStore) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*dedupEntry).key)
+}
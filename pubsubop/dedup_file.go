@@ -0,0 +1,173 @@
+package pubsubop
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dedupRecord is a line of FileDedupStore
+type dedupRecord struct {
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+}
+
+// FileDedupStore is DedupStore of append-only file having a JSON record per line.
+// Every record is synced to disk before Mark returns.
+// The file is compacted on open and by Mark once per TTL by dropping expired keys.
+type FileDedupStore struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu          sync.Mutex
+	f           *os.File
+	marked      map[string]time.Time
+	nextCompact time.Time
+}
+
+// OpenFileDedupStore opens or creates FileDedupStore at path.
+// Record broken by crash in the middle of writing is ignored.
+func OpenFileDedupStore(path string, opts ...DedupStoreOption) (*FileDedupStore, error) {
+	fs := &FileDedupStore{
+		path:   path,
+		ttl:    newDedupOptions(opts).ttl,
+		now:    time.Now,
+		marked: map[string]time.Time{},
+	}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	if err := fs.compact(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fs.f = f
+	return fs, nil
+}
+
+// load reads records of the file
+func (fs *FileDedupStore) load() error {
+	f, err := os.Open(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r dedupRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil || r.Key == "" {
+			continue
+		}
+		fs.marked[r.Key] = r.Time
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", fs.path, err)
+	}
+	return nil
+}
+
+// compact rewrites the file with keys within TTL and forgets the expired keys
+func (fs *FileDedupStore) compact() error {
+	fs.nextCompact = fs.now().Add(fs.ttl)
+	tmp, err := ioutil.TempFile(filepath.Dir(fs.path), filepath.Base(fs.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for key, t := range fs.marked {
+		if fs.expired(t) {
+			delete(fs.marked, key)
+			continue
+		}
+		if err := enc.Encode(dedupRecord{Key: key, Time: t}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path)
+}
+
+// Seen implements DedupStore
+func (fs *FileDedupStore) Seen(key string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t, ok := fs.marked[key]
+	if ok && fs.expired(t) {
+		delete(fs.marked, key)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// Mark implements DedupStore
+func (fs *FileDedupStore) Mark(key string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	now := fs.now()
+	b, err := json.Marshal(dedupRecord{Key: key, Time: now})
+	if err != nil {
+		return err
+	}
+	if _, err := fs.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := fs.f.Sync(); err != nil {
+		return err
+	}
+	fs.marked[key] = now
+	if now.After(fs.nextCompact) {
+		return fs.rotate()
+	}
+	return nil
+}
+
+// rotate compacts the file and switches writing to the compacted one
+func (fs *FileDedupStore) rotate() error {
+	if err := fs.compact(); err != nil {
+		return fmt.Errorf("failed to compact %s: %v", fs.path, err)
+	}
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fs.f.Close()
+	fs.f = f
+	return nil
+}
+
+// Close closes the file
+func (fs *FileDedupStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.f.Close()
+}
+
+func (fs *FileDedupStore) expired(t time.Time) bool {
+	return !fs.now().Before(t.Add(fs.ttl))
+}
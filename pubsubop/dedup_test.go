@@ -0,0 +1,222 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	s := NewMemoryDedupStore(2, WithDedupTTL(time.Hour))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"1", "2"} {
+		if err := s.Mark(key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// 2 is the least recently used after 1 is seen
+	if seen, _ := s.Seen("1"); !seen {
+		t.Error("1 is not seen")
+	}
+	s.Mark("3")
+	if seen, _ := s.Seen("2"); seen {
+		t.Error("2 is seen after eviction")
+	}
+	if seen, _ := s.Seen("1"); !seen {
+		t.Error("1 is not seen")
+	}
+
+	now = now.Add(time.Hour)
+	if seen, _ := s.Seen("3"); seen {
+		t.Error("3 is seen after TTL")
+	}
+	if n := s.Len(); n != 1 {
+		t.Errorf("len: got %d, want %d", n, 1)
+	}
+}
+
+func TestFileDedupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	s, err := OpenFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Mark("1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Close()
+
+	s, err = OpenFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, _ := s.Seen("1"); !seen {
+		t.Error("1 is not seen after reopen")
+	}
+	if seen, _ := s.Seen("2"); seen {
+		t.Error("2 is seen")
+	}
+	s.Close()
+
+	s, err = OpenFileDedupStore(path, WithDedupTTL(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+	if seen, _ := s.Seen("1"); seen {
+		t.Error("1 is seen after TTL")
+	}
+}
+
+func TestFileDedupStore_Compaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	s, err := OpenFileDedupStore(path, WithDedupTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"1", "2"} {
+		if err := s.Mark(key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Keys expired are dropped by Mark after the TTL even if they are not read again
+	now = now.Add(2 * time.Hour)
+	if err := s.Mark("3"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(s.marked); n != 1 {
+		t.Errorf("got %d keys, want %d", n, 1)
+	}
+	// Records are appended to the compacted file
+	if err := s.Mark("4"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 2 || !strings.Contains(string(b), `"key":"3"`) || !strings.Contains(string(b), `"key":"4"`) {
+		t.Errorf("got file\n%s", b)
+	}
+}
+
+// failingDedupStore fails to tell whether key was processed
+type failingDedupStore struct{}
+
+func (failingDedupStore) Seen(key string) (bool, error) { return false, errors.New("unavailable") }
+func (failingDedupStore) Mark(key string) error         { return nil }
+
+func TestDedup(t *testing.T) {
+	calls := 0
+	decision := Nack
+	h := Dedup(func(ctx context.Context, msg *pubsub.Message) Decision {
+		calls++
+		return decision
+	}, NewMemoryDedupStore(10), DedupByAttribute("tweet_id"))
+	ctx := context.Background()
+	msg := &pubsub.Message{ID: "1", Attributes: map[string]string{"tweet_id": "100"}}
+
+	// Nacked message is not marked
+	if d := h(ctx, msg); d.IsAck() || calls != 1 {
+		t.Fatalf("got %v after %d calls, want nack", d, calls)
+	}
+	decision = Ack
+	if d := h(ctx, msg); !d.IsAck() || calls != 2 {
+		t.Fatalf("got %v after %d calls, want ack", d, calls)
+	}
+	// Duplicate is acked without calling the handler
+	dup := &pubsub.Message{ID: "2", Attributes: map[string]string{"tweet_id": "100"}}
+	if d := h(ctx, dup); !d.IsAck() || calls != 2 {
+		t.Errorf("got %v after %d calls, want ack without call", d, calls)
+	}
+	// Message without the key isn't deduplicated
+	h(ctx, &pubsub.Message{ID: "3"})
+	h(ctx, &pubsub.Message{ID: "3"})
+	if calls != 4 {
+		t.Errorf("calls: got %d, want %d", calls, 4)
+	}
+
+	h = Dedup(func(ctx context.Context, msg *pubsub.Message) Decision { return Ack }, failingDedupStore{}, DedupByMessageID)
+	if d := h(ctx, msg); d.IsAck() {
+		t.Error("got ack, want nack on failure of store")
+	}
+}
+
+func TestDedup_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := Dedup(func(ctx context.Context, msg *pubsub.Message) Decision {
+		close(started)
+		<-release
+		return Ack
+	}, NewMemoryDedupStore(10), DedupByPayload)
+	ctx := context.Background()
+
+	done := make(chan Decision)
+	go func() { done <- h(ctx, &pubsub.Message{ID: "1", Data: []byte("hello")}) }()
+	<-started
+	if d := h(ctx, &pubsub.Message{ID: "2", Data: []byte("hello")}); d.IsAck() || d.Delay() != dedupInFlightDelay {
+		t.Errorf("got %v, want nack with delay while in flight", d)
+	}
+	close(release)
+	if d := <-done; !d.IsAck() {
+		t.Errorf("got %v, want ack", d)
+	}
+}
+
+func TestManager_Consume_Dedup(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	for _, id := range []string{"1", "2", "1", "1"} {
+		srv.Publish(testTopic, []byte("tweet "+id), map[string]string{"tweet_id": id})
+	}
+
+	var mu sync.Mutex
+	got := map[string]int{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mgr.Consume(ctx, func(ctx context.Context, msg *pubsub.Message) Decision {
+			mu.Lock()
+			defer mu.Unlock()
+			got[msg.Attributes["tweet_id"]]++
+			return Ack
+		}, WithConcurrency(1), WithDedup(NewMemoryDedupStore(100), DedupByAttribute("tweet_id")))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		acked := 0
+		for _, m := range srv.Messages() {
+			if m.Acks > 0 {
+				acked++
+			}
+		}
+		if acked == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: %d acked", acked)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["1"] != 1 || got["2"] != 1 {
+		t.Errorf("unexpected calls: %v", got)
+	}
+}
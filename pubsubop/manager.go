@@ -4,51 +4,164 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"sync"
-	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// EmulatorHostEnv is environment variable giving address of Pub/Sub emulator
+const EmulatorHostEnv = "PUBSUB_EMULATOR_HOST"
+
+// Manager keeps project, topic and subscription to interact with Cloud Pub/Sub
 type Manager struct {
 	ProjectID string
 	SubName   string
 	TopicName string
 	topic     *pubsub.Topic
 	client    *pubsub.Client
+	subc      *vkit.SubscriberClient
+	mu        sync.Mutex
+	opts      []option.ClientOption
+	ownClient bool
+	ordering  bool
+	settings  *pubsub.PublishSettings
+	schemas   *SchemaRegistry
 }
 
-func (mgr *Manager) InitClient() {
-	ctx := context.Background()
+// Option configures Manager
+type Option func(*Manager)
+
+// WithClient uses c instead of creating new client. c is not closed by Close.
+// Pull uses options given by WithClientOptions to connect to the same server as c.
+func WithClient(c *pubsub.Client) Option {
+	return func(mgr *Manager) {
+		mgr.client = c
+	}
+}
+
+// WithClientOptions sets options used to create client such as option.WithCredentialsFile
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(mgr *Manager) {
+		mgr.opts = append(mgr.opts, opts...)
+	}
+}
+
+// NewManager creates Manager of the topic and the subscription.
+// The client connects to the emulator when PUBSUB_EMULATOR_HOST is set.
+func NewManager(ctx context.Context, projectID, topicName, subName string, opts ...Option) (*Manager, error) {
+	mgr := &Manager{
+		ProjectID: projectID,
+		TopicName: topicName,
+		SubName:   subName,
+	}
+	for _, opt := range opts {
+		opt(mgr)
+	}
+	if mgr.client != nil {
+		return mgr, nil
+	}
+	if err := mgr.Init(ctx, mgr.opts...); err != nil {
+		return nil, err
+	}
+	return mgr, nil
+}
 
-	client, err := pubsub.NewClient(ctx, mgr.ProjectID)
+// Init creates client of the project by opts
+func (mgr *Manager) Init(ctx context.Context, opts ...option.ClientOption) error {
+	client, err := pubsub.NewClient(ctx, mgr.ProjectID, opts...)
 	if err != nil {
-		log.Fatalf("Could not create pubsub Client: %v", err)
+		return fmt.Errorf("failed to create pubsub client: %w", err)
 	}
 	mgr.client = client
+	mgr.opts = opts
+	mgr.ownClient = true
+	return nil
+}
+
+// InitClient creates client of the project and exits the process on failure.
+//
+// Deprecated: use NewManager or Init which return error.
+func (mgr *Manager) InitClient() {
+	if err := mgr.Init(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 }
 
+// Close sends messages published and closes clients created by Manager
+func (mgr *Manager) Close() error {
+	mgr.Stop()
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	var err error
+	if mgr.subc != nil {
+		err = mgr.subc.Close()
+		mgr.subc = nil
+	}
+	if mgr.ownClient && mgr.client != nil {
+		if cerr := mgr.client.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// subscriber gives client of low level API which is created on first use
+func (mgr *Manager) subscriber(ctx context.Context) (*vkit.SubscriberClient, error) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.subc != nil {
+		return mgr.subc, nil
+	}
+	subc, err := vkit.NewSubscriberClient(ctx, append(emulatorOptions(), mgr.opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub subscriber client: %w", err)
+	}
+	mgr.subc = subc
+	return subc, nil
+}
+
+// emulatorOptions gives options to connect to the emulator as pubsub.NewClient does
+func emulatorOptions() []option.ClientOption {
+	addr := os.Getenv(EmulatorHostEnv)
+	if addr == "" {
+		return nil
+	}
+	return []option.ClientOption{
+		option.WithEndpoint(addr),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+		option.WithTelemetryDisabled(),
+	}
+}
+
+// PullMessages gives data of up to 5 messages as string
 func (mgr *Manager) PullMessages() ([]string, error) {
-	ctx := context.Background()
+	const max = 5
 	messages := []string{}
 
 	var mu sync.Mutex
-	received := 0
-	sub := mgr.client.Subscription(mgr.SubName)
-	cctx, cancel := context.WithCancel(ctx)
-
-	err := sub.Receive(cctx, func(ctx context.Context, msg *pubsub.Message) {
-		msg.Ack()
-		m := string(msg.Data)
-		messages = append(messages, m)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := mgr.Consume(ctx, func(ctx context.Context, msg *pubsub.Message) Decision {
 		mu.Lock()
 		defer mu.Unlock()
-		received++
-		if received >= 5 {
+		if len(messages) >= max {
+			return Nack
+		}
+		messages = append(messages, string(msg.Data))
+		if len(messages) >= max {
 			cancel()
 		}
+		return Ack
 	})
 
+	mu.Lock()
+	defer mu.Unlock()
 	return messages, err
 }
 
@@ -59,71 +172,42 @@ func (mgr *Manager) PublishStr(msg string) error {
 
 // Publish sends data given as array of byte to pubsub server
 func (mgr *Manager) Publish(msg []byte) error {
-	ctx := context.Background()
-	t := mgr.getTopic()
-	log.Printf(string(msg))
-	result := t.Publish(ctx, &pubsub.Message{
-		Data: msg,
-	})
-	// Block until the result is returned and id server-generated
-	// ID is for the published message
-	id, err := result.Get(ctx)
-	if err != nil {
-		return err
-	}
-	fmt.Printf("Published a message; msg ID: %v\n", id)
-	return nil
+	_, err := mgr.PublishMessage(context.Background(), Message{Data: msg})
+	return err
 }
 
-// CreateSub creates subscription
-func (mgr *Manager) CreateSub() error {
-	t := mgr.getTopic()
-	err := mgr.createSub(mgr.SubName, t)
-	if err != nil {
-		return err
+func (mgr *Manager) getTopic(ctx context.Context) (*pubsub.Topic, error) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.topic != nil {
+		return mgr.topic, nil
 	}
-	return nil
-}
-
-func (mgr *Manager) createSub(subName string, topic *pubsub.Topic) error {
-	ctx := context.Background()
-	sub, err := mgr.client.CreateSubscription(ctx, subName, pubsub.SubscriptionConfig{
-		Topic:       topic,
-		AckDeadline: 30 * time.Second,
-	})
+	t, err := mgr.createTopicIfNotExists(ctx, mgr.TopicName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Created subscription: %v\n", sub)
-	// [END pubsub_create_pull_subscription]
-	return nil
-}
-
-func (mgr *Manager) getTopic() *pubsub.Topic {
-	if mgr.topic != nil {
-		return mgr.topic
+	if mgr.settings != nil {
+		t.PublishSettings = *mgr.settings
 	}
-	t := mgr.createTopicIfNotExists(mgr.TopicName)
+	t.EnableMessageOrdering = mgr.ordering
 	mgr.topic = t
-	return t
+	return t, nil
 }
 
-func (mgr *Manager) createTopicIfNotExists(topicName string) *pubsub.Topic {
-	ctx := context.Background()
-
+func (mgr *Manager) createTopicIfNotExists(ctx context.Context, topicName string) (*pubsub.Topic, error) {
 	t := mgr.client.Topic(topicName)
 
 	ok, err := t.Exists(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	if ok {
-		return t
+		return t, nil
 	}
 
 	t, err = mgr.client.CreateTopic(ctx, topicName)
 	if err != nil {
-		log.Fatalf("Failed to create the TopicName: %v", err)
+		return nil, fmt.Errorf("failed to create the topic: %w", err)
 	}
-	return t
+	return t, nil
 }
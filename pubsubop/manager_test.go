@@ -0,0 +1,145 @@
+package pubsubop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const testTopic = "projects/test-project/topics/tweets"
+
+// newTestServer starts in-memory Pub/Sub and gives options to connect to it
+func newTestServer(t *testing.T, opts ...pstest.ServerReactorOption) (*pstest.Server, []option.ClientOption) {
+	t.Helper()
+	srv := pstest.NewServer(opts...)
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Close()
+	})
+	return srv, []option.ClientOption{option.WithGRPCConn(conn)}
+}
+
+// newTestManager gives Manager connected to in-memory Pub/Sub having the topic and the subscription
+func newTestManager(t *testing.T) (*pstest.Server, *Manager) {
+	t.Helper()
+	srv, opts := newTestServer(t)
+	mgr, err := NewManager(context.Background(), "test-project", "tweets", "tweets-sub", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	if err := mgr.CreateSub(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return srv, mgr
+}
+
+func TestManager_Publish(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-sub", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	// The topic is created on first publish
+	if err := mgr.PublishStr("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ok, err := mgr.client.Topic("tweets").Exists(ctx)
+	if err != nil || !ok {
+		t.Fatalf("topic is not created: %v", err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || string(msgs[0].Data) != "hello" || msgs[0].Topic != testTopic {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
+
+func TestManager_CreateSub(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	ctx := context.Background()
+	cfg, err := mgr.client.Subscription("tweets-sub").Config(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Topic.ID() != "tweets" {
+		t.Errorf("topic: got %s, want %s", cfg.Topic.ID(), "tweets")
+	}
+	if err := mgr.CreateSub(); err == nil {
+		t.Error("got nil, want error of existing subscription")
+	}
+
+	srv.Publish(testTopic, []byte("hello"), nil)
+	msgs, err := mgr.Pull(ctx, PullParams{MaxMessages: 1, MaxWait: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 1 || string(msgs[0].Data) != "hello" {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
+
+func TestNewManager_WithClient(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	owner, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer owner.Close()
+
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClient(owner.client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.PublishStr("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Client given by WithClient is left open
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := owner.PublishStr("world"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(srv.Messages()); n != 2 {
+		t.Errorf("got %d messages, want %d", n, 2)
+	}
+}
+
+func TestNewManager_Emulator(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+	t.Setenv(EmulatorHostEnv, srv.Addr)
+
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-sub")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if err := mgr.CreateSub(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.PublishStr("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msgs, err := mgr.Pull(ctx, PullParams{MaxMessages: 1, MaxWait: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 1 || string(msgs[0].Data) != "hello" {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
@@ -0,0 +1,296 @@
+package pubsubop
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoogleJWKSURL is where public keys of Google signing OIDC tokens are published
+const GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// Errors of token verification
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token is expired")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// allowedSkew is allowed difference of clocks of Google and the server
+const allowedSkew = time.Minute
+
+// googleIssuers are issuers of OIDC tokens of Google
+var googleIssuers = map[string]bool{
+	"accounts.google.com":         true,
+	"https://accounts.google.com": true,
+}
+
+// KeySource gives public key to verify token signed by the key ID
+type KeySource interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// Claims is claims of OIDC token used by Pub/Sub push
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	IssuedAt      int64    `json:"iat"`
+	Expiry        int64    `json:"exp"`
+}
+
+// audience is "aud" claim which is string or array of string
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenVerifier verifies OIDC token signed by Google for the audience
+type TokenVerifier struct {
+	audience       string
+	serviceAccount string
+	keys           KeySource
+	now            func() time.Time
+}
+
+// NewTokenVerifier creates TokenVerifier of the audience checking signature by keys.
+// serviceAccount is verified email expected in the token, so token without email is rejected when it is empty.
+func NewTokenVerifier(aud, serviceAccount string, keys KeySource) *TokenVerifier {
+	return &TokenVerifier{audience: aud, serviceAccount: serviceAccount, keys: keys, now: time.Now}
+}
+
+// Verify checks signature and claims of the token
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	now := v.now()
+	if now.After(time.Unix(claims.Expiry, 0).Add(allowedSkew)) {
+		return nil, ErrExpiredToken
+	}
+	if now.Add(allowedSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if !googleIssuers[claims.Issuer] {
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+	if !claims.Audience.contains(v.audience) {
+		return nil, fmt.Errorf("%w: audience %v", ErrInvalidToken, claims.Audience)
+	}
+	if claims.Email == "" || claims.Email != v.serviceAccount || !claims.EmailVerified {
+		return nil, fmt.Errorf("%w: email %q", ErrInvalidToken, claims.Email)
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// JWKSOption configures JWKS
+type JWKSOption func(*JWKS)
+
+// WithJWKSHTTPClient sets http.Client to fetch keys
+func WithJWKSHTTPClient(c *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = c
+	}
+}
+
+// WithJWKSRefreshInterval sets how long fetched keys are used when the response has no max-age
+func WithJWKSRefreshInterval(d time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.refresh = d
+	}
+}
+
+// JWKS is KeySource fetching JSON Web Key Set from URL.
+// Keys are cached by max-age of the response and fetched again when unknown key ID is given.
+type JWKS struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+	// minInterval limits fetches caused by unknown key IDs
+	minInterval time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	expiry  time.Time
+	fetched time.Time
+}
+
+// NewJWKS creates KeySource of url such as GoogleJWKSURL
+func NewJWKS(url string, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		url:         url,
+		client:      http.DefaultClient,
+		refresh:     time.Hour,
+		minInterval: 10 * time.Second,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Key gives public key of kid
+func (j *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	key, ok := j.keys[kid]
+	if ok && now.Before(j.expiry) {
+		return key, nil
+	}
+	if j.keys == nil || now.After(j.expiry) || now.Sub(j.fetched) >= j.minInterval {
+		if err := j.fetch(ctx); err != nil {
+			if ok {
+				// Keep using the key while the source is unavailable
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// jwk is RSA key of JSON Web Key
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to fetch keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch keys: status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode keys: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	now := j.now()
+	j.keys = keys
+	j.fetched = now
+	j.expiry = now.Add(maxAge(resp.Header.Get("Cache-Control"), j.refresh))
+	return nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// maxAge gives max-age of Cache-Control or def
+func maxAge(cacheControl string, def time.Duration) time.Duration {
+	for _, d := range strings.Split(cacheControl, ",") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, "max-age=") {
+			if sec, err := strconv.Atoi(strings.TrimPrefix(d, "max-age=")); err == nil {
+				return time.Duration(sec) * time.Second
+			}
+		}
+	}
+	return def
+}
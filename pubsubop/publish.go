@@ -0,0 +1,204 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// ErrOrderingDisabled is returned when message has OrderingKey but WithMessageOrdering is not given
+var ErrOrderingDisabled = errors.New("ordering key is given but message ordering is not enabled")
+
+// Message is message to publish
+type Message struct {
+	Data       []byte
+	Attributes map[string]string
+	// OrderingKey makes messages of the same key delivered in the order of publish.
+	// It needs WithMessageOrdering.
+	OrderingKey string
+}
+
+// WithMessageOrdering enables ordering of messages having the same OrderingKey
+func WithMessageOrdering() Option {
+	return func(mgr *Manager) {
+		mgr.ordering = true
+	}
+}
+
+// WithBatchSize sets the number of messages sent to the server at once
+func WithBatchSize(n int) Option {
+	return func(mgr *Manager) {
+		mgr.publishSettings().CountThreshold = n
+	}
+}
+
+// WithDelayThreshold sets how long messages are buffered before they are sent
+func WithDelayThreshold(d time.Duration) Option {
+	return func(mgr *Manager) {
+		mgr.publishSettings().DelayThreshold = d
+	}
+}
+
+// WithByteThreshold sets the size of messages which makes a batch to be sent
+func WithByteThreshold(n int) Option {
+	return func(mgr *Manager) {
+		mgr.publishSettings().ByteThreshold = n
+	}
+}
+
+// WithPublishConcurrency sets the number of goroutines sending batches to the server
+func WithPublishConcurrency(n int) Option {
+	return func(mgr *Manager) {
+		mgr.publishSettings().NumGoroutines = n
+	}
+}
+
+// PublishMessage publishes msg and gives message ID generated by the server.
+// Publish of the ordering key is resumed on failure so that the caller can publish it again.
+func (mgr *Manager) PublishMessage(ctx context.Context, msg Message) (string, error) {
+	return mgr.PublishAsync(ctx, msg).Get(ctx)
+}
+
+// PublishResult is handle of message published by PublishAsync
+type PublishResult struct {
+	res   *pubsub.PublishResult
+	err   error
+	ready <-chan struct{}
+}
+
+// Ready is closed when the result is available
+func (r *PublishResult) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// Get blocks until the message is published and gives its ID
+func (r *PublishResult) Get(ctx context.Context) (string, error) {
+	select {
+	case <-r.ready:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.res.Get(context.Background())
+}
+
+// failedResult gives PublishResult having err
+func failedResult(err error) *PublishResult {
+	ready := make(chan struct{})
+	close(ready)
+	return &PublishResult{err: err, ready: ready}
+}
+
+// PublishAsync publishes msg without waiting for the server.
+// Messages are batched by the publish settings and sent in background.
+// Publish of the ordering key is resumed on failure before the result gets ready.
+func (mgr *Manager) PublishAsync(ctx context.Context, msg Message) *PublishResult {
+	if msg.OrderingKey != "" && !mgr.ordering {
+		return failedResult(ErrOrderingDisabled)
+	}
+	if mgr.schemas != nil {
+		var err error
+		if msg, err = mgr.validate(msg); err != nil {
+			return failedResult(err)
+		}
+	}
+	t, err := mgr.getTopic(ctx)
+	if err != nil {
+		return failedResult(err)
+	}
+	res := t.Publish(ctx, &pubsub.Message{
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+	})
+	r := &PublishResult{res: res, ready: res.Ready()}
+	if msg.OrderingKey != "" {
+		// Publish of the key is resumed on failure even when Get is not called or its ctx is done
+		ready := make(chan struct{})
+		r.ready = ready
+		go func() {
+			defer close(ready)
+			if _, err := res.Get(context.Background()); err != nil {
+				t.ResumePublish(msg.OrderingKey)
+			}
+		}()
+	}
+	return r
+}
+
+// BatchError has errors of messages which failed to be published keyed by the index
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	idx := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	msgs := make([]string, 0, len(idx))
+	for _, i := range idx {
+		msgs = append(msgs, strconv.Itoa(i)+": "+e.Errors[i].Error())
+	}
+	return "failed to publish " + strings.Join(msgs, "; ")
+}
+
+// PublishBatch publishes msgs concurrently and gives IDs in the same order.
+// ID of the message failed is empty and error is *BatchError when any of them failed.
+func (mgr *Manager) PublishBatch(ctx context.Context, msgs []Message) ([]string, error) {
+	results := make([]*PublishResult, len(msgs))
+	for i, msg := range msgs {
+		results[i] = mgr.PublishAsync(ctx, msg)
+	}
+	ids := make([]string, len(msgs))
+	errs := map[int]error{}
+	for i, r := range results {
+		id, err := r.Get(ctx)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		ids[i] = id
+	}
+	if len(errs) > 0 {
+		return ids, &BatchError{Errors: errs}
+	}
+	return ids, nil
+}
+
+// Flush blocks until all messages published are sent
+func (mgr *Manager) Flush() {
+	mgr.mu.Lock()
+	t := mgr.topic
+	mgr.mu.Unlock()
+	if t != nil {
+		t.Flush()
+	}
+}
+
+// Stop sends all messages published and stops publishing.
+// Messages published after Stop fail.
+func (mgr *Manager) Stop() {
+	mgr.mu.Lock()
+	t := mgr.topic
+	mgr.mu.Unlock()
+	if t != nil {
+		t.Stop()
+	}
+}
+
+func (mgr *Manager) publishSettings() *pubsub.PublishSettings {
+	if mgr.settings == nil {
+		s := pubsub.DefaultPublishSettings
+		mgr.settings = &s
+	}
+	return mgr.settings
+}
@@ -0,0 +1,154 @@
+package pubsubop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestManager_PublishMessage(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...),
+		WithMessageOrdering(), WithBatchSize(10), WithDelayThreshold(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	id, err := mgr.PublishMessage(ctx, Message{
+		Data:        []byte("hello"),
+		Attributes:  map[string]string{"lang": "en"},
+		OrderingKey: "Cloud Google",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := srv.Message(id)
+	if m == nil {
+		t.Fatalf("message %s is not published", id)
+	}
+	if string(m.Data) != "hello" || m.Attributes["lang"] != "en" || m.OrderingKey != "Cloud Google" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+}
+
+func TestManager_PublishMessage_Resume(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...), WithMessageOrdering())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.getTopic(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.InvalidArgument, "rejected"))
+	msg := Message{Data: []byte("hello"), OrderingKey: "Cloud Google"}
+	if _, err := mgr.PublishMessage(ctx, msg); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	srv.SetAutoPublishResponse(true)
+	if _, err := mgr.PublishMessage(ctx, msg); err != nil {
+		t.Errorf("ordering key is not resumed: %v", err)
+	}
+}
+
+func TestManager_PublishAsync_ResumeWithoutGet(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...), WithMessageOrdering())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.getTopic(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.InvalidArgument, "rejected"))
+	msg := Message{Data: []byte("hello"), OrderingKey: "Cloud Google"}
+	pctx, cancel := context.WithCancel(ctx)
+	res := mgr.PublishAsync(pctx, msg)
+	cancel()
+	select {
+	case <-res.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	srv.SetAutoPublishResponse(true)
+	if _, err := mgr.PublishMessage(ctx, msg); err != nil {
+		t.Errorf("ordering key is not resumed: %v", err)
+	}
+}
+
+func TestManager_PublishMessage_OrderingDisabled(t *testing.T) {
+	_, mgr := newTestManager(t)
+	_, err := mgr.PublishMessage(context.Background(), Message{Data: []byte("hello"), OrderingKey: "k"})
+	if err != ErrOrderingDisabled {
+		t.Errorf("got %v, want %v", err, ErrOrderingDisabled)
+	}
+}
+
+func TestManager_PublishAsync(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...), WithDelayThreshold(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	var results []*PublishResult
+	for _, data := range []string{"a", "b", "c"} {
+		results = append(results, mgr.PublishAsync(ctx, Message{Data: []byte(data)}))
+	}
+	select {
+	case <-results[0].Ready():
+		t.Fatal("published before flush")
+	default:
+	}
+	mgr.Flush()
+	for _, r := range results {
+		if _, err := r.Get(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if n := len(srv.Messages()); n != 3 {
+		t.Errorf("got %d messages, want %d", n, 3)
+	}
+
+	mgr.Stop()
+	if _, err := mgr.PublishMessage(ctx, Message{Data: []byte("late")}); err == nil {
+		t.Error("got nil, want error after stop")
+	}
+}
+
+func TestManager_PublishBatch(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	ids, err := mgr.PublishBatch(context.Background(), []Message{
+		{Data: []byte("a")},
+		{Data: []byte("b"), OrderingKey: "k"},
+		{Data: []byte("c")},
+	})
+	berr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("got %v, want *BatchError", err)
+	}
+	if len(berr.Errors) != 1 || berr.Errors[1] != ErrOrderingDisabled {
+		t.Errorf("unexpected errors: %v", berr)
+	}
+	if len(ids) != 3 || ids[0] == "" || ids[1] != "" || ids[2] == "" {
+		t.Fatalf("unexpected ids: %q", ids)
+	}
+	if m := srv.Message(ids[2]); m == nil || string(m.Data) != "c" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+}
@@ -0,0 +1,173 @@
+package pubsubop
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+)
+
+// AckMode tells how messages given by Pull are acknowledged
+type AckMode int
+
+const (
+	// AckAfterRead acknowledges messages before Pull returns them
+	AckAfterRead AckMode = iota
+	// AckManual leaves messages to be acknowledged by AckMessages or NackMessages with their AckID
+	AckManual
+)
+
+// DefaultMaxMessages is the number of messages pulled when PullParams.MaxMessages is not set
+const DefaultMaxMessages = 10
+
+// Delay of Pull RPC after empty response, doubled up to the max while no message arrives
+const (
+	emptyPullDelay    = 100 * time.Millisecond
+	maxEmptyPullDelay = 2 * time.Second
+)
+
+// PullParams is parameters of Pull
+type PullParams struct {
+	// MaxMessages is the maximum number of messages to return
+	MaxMessages int
+	// MaxWait is how long Pull waits for MaxMessages to arrive. Zero waits until ctx is done.
+	MaxWait time.Duration
+	AckMode AckMode
+}
+
+// ReceivedMessage is message given by Pull
+type ReceivedMessage struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	PublishTime time.Time
+	// DeliveryAttempt is 0 unless dead lettering is enabled on the subscription
+	DeliveryAttempt int
+	OrderingKey     string
+	// AckID is the handle to acknowledge the message when AckManual is used
+	AckID string
+}
+
+// Pull receives up to p.MaxMessages messages of the subscription.
+// It returns early with the messages arrived so far when p.MaxWait passes or ctx is done.
+// Messages received are nacked when an error is returned.
+func (mgr *Manager) Pull(ctx context.Context, p PullParams) ([]*ReceivedMessage, error) {
+	max := p.MaxMessages
+	if max <= 0 {
+		max = DefaultMaxMessages
+	}
+	wctx := ctx
+	if p.MaxWait > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, p.MaxWait)
+		defer cancel()
+	}
+
+	subc, err := mgr.subscriber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sub := mgr.subscriptionName()
+	var msgs []*ReceivedMessage
+	delay := emptyPullDelay
+	for len(msgs) < max {
+		res, err := subc.Pull(wctx, &pubsubpb.PullRequest{
+			Subscription: sub,
+			MaxMessages:  int32(max - len(msgs)),
+		})
+		if err == nil && len(res.ReceivedMessages) == 0 {
+			err = sleepContext(wctx, delay)
+			if delay *= 2; delay > maxEmptyPullDelay {
+				delay = maxEmptyPullDelay
+			}
+		}
+		if err != nil {
+			if wctx.Err() != nil && (ctx.Err() == nil || len(msgs) > 0) {
+				break
+			}
+			if len(msgs) > 0 {
+				mgr.NackMessages(context.Background(), ackIDs(msgs)...)
+			}
+			return nil, err
+		}
+		for _, rm := range res.ReceivedMessages {
+			msgs = append(msgs, receivedMessage(rm))
+		}
+	}
+
+	if p.AckMode == AckAfterRead && len(msgs) > 0 {
+		// Messages returned on cancel of ctx are still acknowledged
+		if err := mgr.AckMessages(context.WithoutCancel(ctx), ackIDs(msgs)...); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+// sleepContext waits for d, or returns the error of ctx when it's done earlier
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// AckMessages acknowledges the messages of ackIDs
+func (mgr *Manager) AckMessages(ctx context.Context, ackIDs ...string) error {
+	if len(ackIDs) == 0 {
+		return nil
+	}
+	subc, err := mgr.subscriber(ctx)
+	if err != nil {
+		return err
+	}
+	return subc.Acknowledge(ctx, &pubsubpb.AcknowledgeRequest{
+		Subscription: mgr.subscriptionName(),
+		AckIds:       ackIDs,
+	})
+}
+
+// NackMessages makes the messages of ackIDs to be redelivered immediately
+func (mgr *Manager) NackMessages(ctx context.Context, ackIDs ...string) error {
+	if len(ackIDs) == 0 {
+		return nil
+	}
+	subc, err := mgr.subscriber(ctx)
+	if err != nil {
+		return err
+	}
+	return subc.ModifyAckDeadline(ctx, &pubsubpb.ModifyAckDeadlineRequest{
+		Subscription:       mgr.subscriptionName(),
+		AckIds:             ackIDs,
+		AckDeadlineSeconds: 0,
+	})
+}
+
+func (mgr *Manager) subscriptionName() string {
+	return mgr.client.Subscription(mgr.SubName).String()
+}
+
+func receivedMessage(rm *pubsubpb.ReceivedMessage) *ReceivedMessage {
+	m := rm.GetMessage()
+	return &ReceivedMessage{
+		ID:              m.GetMessageId(),
+		Data:            m.GetData(),
+		Attributes:      m.GetAttributes(),
+		PublishTime:     m.GetPublishTime().AsTime(),
+		DeliveryAttempt: int(rm.GetDeliveryAttempt()),
+		OrderingKey:     m.GetOrderingKey(),
+		AckID:           rm.GetAckId(),
+	}
+}
+
+func ackIDs(msgs []*ReceivedMessage) []string {
+	ids := make([]string, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.AckID
+	}
+	return ids
+}
@@ -0,0 +1,146 @@
+package pubsubop
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
+)
+
+func TestManager_Pull(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	id := srv.PublishOrdered(testTopic, []byte("hello"), map[string]string{"lang": "en"}, "Cloud Google")
+	srv.Publish(testTopic, []byte("world"), nil)
+
+	ctx := context.Background()
+	start := time.Now()
+	msgs, err := mgr.Pull(ctx, PullParams{MaxMessages: 5, MaxWait: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("returned after %v, want about %v", d, time.Second)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want %d", len(msgs), 2)
+	}
+	var got *ReceivedMessage
+	for _, m := range msgs {
+		if m.ID == id {
+			got = m
+		}
+	}
+	if got == nil {
+		t.Fatalf("message %s is not pulled", id)
+	}
+	if string(got.Data) != "hello" || got.Attributes["lang"] != "en" || got.OrderingKey != "Cloud Google" ||
+		got.PublishTime.IsZero() || got.AckID == "" {
+		t.Errorf("unexpected message: %+v", got)
+	}
+	for _, m := range srv.Messages() {
+		if m.Acks != 1 {
+			t.Errorf("%s: acks got %d, want %d", m.Data, m.Acks, 1)
+		}
+	}
+}
+
+func TestManager_Pull_Manual(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	for _, data := range []string{"a", "b", "c"} {
+		srv.Publish(testTopic, []byte(data), nil)
+	}
+
+	ctx := context.Background()
+	msgs, err := mgr.Pull(ctx, PullParams{MaxMessages: 2, MaxWait: time.Second, AckMode: AckManual})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want %d", len(msgs), 2)
+	}
+	for _, m := range srv.Messages() {
+		if m.Acks != 0 {
+			t.Fatalf("%s is acknowledged before AckMessages", m.Data)
+		}
+	}
+
+	if err := mgr.AckMessages(ctx, msgs[0].AckID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.NackMessages(ctx, msgs[1].AckID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m := srv.Message(msgs[0].ID); m.Acks != 1 {
+		t.Errorf("acks got %d, want %d", m.Acks, 1)
+	}
+
+	// The nacked one and the one left are delivered again
+	rest, err := mgr.Pull(ctx, PullParams{MaxMessages: 5, MaxWait: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rest) != 2 {
+		t.Errorf("got %d messages, want %d", len(rest), 2)
+	}
+}
+
+func TestManager_Pull_CanceledWithMessages(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	srv.Publish(testTopic, []byte("a"), nil)
+	srv.Publish(testTopic, []byte("b"), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgs, err := mgr.Pull(ctx, PullParams{MaxMessages: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want %d", len(msgs), 2)
+	}
+	for _, m := range srv.Messages() {
+		if m.Acks != 1 {
+			t.Errorf("%s: acks got %d, want %d", m.Data, m.Acks, 1)
+		}
+	}
+}
+
+// emptyPulls answers Pull without messages and counts the calls
+type emptyPulls struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (r *emptyPulls) React(interface{}) (bool, interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.n++
+	return true, &pubsubpb.PullResponse{}, nil
+}
+
+func TestManager_Pull_EmptyResponses(t *testing.T) {
+	pulls := &emptyPulls{}
+	_, opts := newTestServer(t, pstest.ServerReactorOption{FuncName: "Pull", Reactor: pulls})
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-sub", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if err := mgr.CreateSub(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msgs, err := mgr.Pull(ctx, PullParams{MaxWait: time.Second})
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("got %v %v, want no message", msgs, err)
+	}
+	pulls.mu.Lock()
+	defer pulls.mu.Unlock()
+	if pulls.n > 5 {
+		t.Errorf("got %d pulls in %v, want backoff between empty responses", pulls.n, time.Second)
+	}
+}
@@ -0,0 +1,113 @@
+package pubsubop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxPushBody is the maximum size of push request. Message of Pub/Sub is up to 10MB and base64 encoded in the body.
+const maxPushBody = 16 << 20
+
+// ErrMissingServiceAccount is returned when PushHandler is created without the service account of the push subscription
+var ErrMissingServiceAccount = errors.New("service account of push subscription is required")
+
+// PushRequest is body of push request of Pub/Sub
+type PushRequest struct {
+	Message         PushMessage `json:"message"`
+	Subscription    string      `json:"subscription"`
+	DeliveryAttempt int         `json:"deliveryAttempt"`
+}
+
+// PushMessage is message in PushRequest
+type PushMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	MessageID   string            `json:"messageId"`
+	PublishTime time.Time         `json:"publishTime"`
+	OrderingKey string            `json:"orderingKey"`
+}
+
+// PushHandlerFunc processes message pushed by Pub/Sub.
+// AckID of the message is empty since the message is settled by the response.
+type PushHandlerFunc func(ctx context.Context, msg *ReceivedMessage) Decision
+
+// PushOption configures PushHandler
+type PushOption func(*PushHandler)
+
+// WithKeySource sets source of public keys verifying token instead of GoogleJWKSURL
+func WithKeySource(keys KeySource) PushOption {
+	return func(h *PushHandler) {
+		h.keys = keys
+	}
+}
+
+// PushHandler is http.Handler receiving messages of push subscription.
+// It verifies OIDC token in Authorization header and responds 204 for Ack and 500 for Nack.
+// Pub/Sub redelivers the message by backoff of the subscription for responses other than 2xx.
+type PushHandler struct {
+	handle   PushHandlerFunc
+	keys     KeySource
+	verifier *TokenVerifier
+}
+
+// NewPushHandler creates PushHandler accepting token of the audience and the service account email
+// given to the push subscription. The service account is required since any Google account can get
+// token of arbitrary audience.
+func NewPushHandler(aud, serviceAccount string, h PushHandlerFunc, opts ...PushOption) (*PushHandler, error) {
+	if serviceAccount == "" {
+		return nil, ErrMissingServiceAccount
+	}
+	ph := &PushHandler{handle: h}
+	for _, opt := range opts {
+		opt(ph)
+	}
+	if ph.keys == nil {
+		ph.keys = NewJWKS(GoogleJWKSURL)
+	}
+	ph.verifier = NewTokenVerifier(aud, serviceAccount, ph.keys)
+	return ph, nil
+}
+
+func (h *PushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		http.Error(w, ErrMissingToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.verifier.Verify(r.Context(), token); err != nil {
+		status := http.StatusForbidden
+		if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrExpiredToken) && !errors.Is(err, ErrUnknownKey) {
+			// Keys couldn't be fetched
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var req PushRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid push request", http.StatusBadRequest)
+		return
+	}
+	msg := &ReceivedMessage{
+		ID:              req.Message.MessageID,
+		Data:            req.Message.Data,
+		Attributes:      req.Message.Attributes,
+		PublishTime:     req.Message.PublishTime,
+		DeliveryAttempt: req.DeliveryAttempt,
+		OrderingKey:     req.Message.OrderingKey,
+	}
+	if d := h.handle(r.Context(), msg); !d.IsAck() {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,228 @@
+package pubsubop
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testAudience       = "https://crunsample.run.app/pubsub/push"
+	testServiceAccount = "push@test-project.iam.gserviceaccount.com"
+)
+
+// keyServer serves JWKS of keys generated for tests
+type keyServer struct {
+	*httptest.Server
+	keys    map[string]*rsa.PrivateKey
+	fetches int32
+}
+
+func newKeyServer(t *testing.T, kids ...string) *keyServer {
+	t.Helper()
+	ks := &keyServer{keys: map[string]*rsa.PrivateKey{}}
+	for _, kid := range kids {
+		ks.addKey(t, kid)
+	}
+	ks.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ks.fetches, 1)
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		for kid, k := range ks.keys {
+			set.Keys = append(set.Keys, jwk{
+				Kid: kid,
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(ks.Close)
+	return ks
+}
+
+func (ks *keyServer) addKey(t *testing.T, kid string) {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ks.keys[kid] = k
+}
+
+// sign gives RS256 token of claims signed by the key of kid
+func (ks *keyServer) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signing := enc(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ks.keys[kid], crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"email":          testServiceAccount,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func pushRequest(token string) *http.Request {
+	body := `{"message":{"data":"aGVsbG8=","attributes":{"lang":"en"},"messageId":"123","publishTime":"2020-02-25T10:00:00Z","orderingKey":"k"},` +
+		`"subscription":"projects/test-project/subscriptions/tweets-push","deliveryAttempt":2}`
+	r := httptest.NewRequest(http.MethodPost, "/pubsub/push", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestPushHandler(t *testing.T) {
+	ks := newKeyServer(t, "k1")
+	var got *ReceivedMessage
+	decision := Ack
+	h, err := NewPushHandler(testAudience, testServiceAccount, func(ctx context.Context, msg *ReceivedMessage) Decision {
+		got = msg
+		return decision
+	}, WithKeySource(NewJWKS(ks.URL)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	token := ks.sign(t, "k1", testClaims(time.Now()))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, pushRequest(token))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status: got %d, want %d: %s", w.Code, http.StatusNoContent, w.Body)
+	}
+	if got == nil || string(got.Data) != "hello" || got.ID != "123" || got.Attributes["lang"] != "en" ||
+		got.OrderingKey != "k" || got.DeliveryAttempt != 2 || got.PublishTime.IsZero() {
+		t.Errorf("unexpected message: %+v", got)
+	}
+
+	decision = NackWithDelay(time.Minute)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, pushRequest(token))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	r := pushRequest(token)
+	r.Body = http.NoBody
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestNewPushHandler_MissingServiceAccount(t *testing.T) {
+	_, err := NewPushHandler(testAudience, "", func(ctx context.Context, msg *ReceivedMessage) Decision { return Ack })
+	if err != ErrMissingServiceAccount {
+		t.Errorf("got %v, want %v", err, ErrMissingServiceAccount)
+	}
+}
+
+func TestPushHandler_Unauthorized(t *testing.T) {
+	ks := newKeyServer(t, "k1", "k2")
+	other := newKeyServer(t, "k1")
+	now := time.Now()
+	claims := func(key string, value interface{}) map[string]interface{} {
+		c := testClaims(now)
+		c[key] = value
+		return c
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "missing", token: "", want: http.StatusUnauthorized},
+		{name: "malformed", token: "abc", want: http.StatusForbidden},
+		{name: "other key", token: other.sign(t, "k1", testClaims(now)), want: http.StatusForbidden},
+		{name: "unknown kid", token: signWithKid(t, other, "k3"), want: http.StatusForbidden},
+		{name: "expired", token: ks.sign(t, "k1", claims("exp", now.Add(-time.Hour).Unix())), want: http.StatusForbidden},
+		{name: "audience", token: ks.sign(t, "k1", claims("aud", "https://other.run.app")), want: http.StatusForbidden},
+		{name: "issuer", token: ks.sign(t, "k1", claims("iss", "https://evil.example.com")), want: http.StatusForbidden},
+		{name: "service account", token: ks.sign(t, "k1", claims("email", "other@test-project.iam.gserviceaccount.com")), want: http.StatusForbidden},
+		{name: "no email", token: ks.sign(t, "k1", claims("email", "")), want: http.StatusForbidden},
+		{name: "email not verified", token: ks.sign(t, "k1", claims("email_verified", false)), want: http.StatusForbidden},
+		{name: "second key", token: ks.sign(t, "k2", testClaims(now)), want: http.StatusNoContent},
+	}
+	h, err := NewPushHandler(testAudience, testServiceAccount, func(ctx context.Context, msg *ReceivedMessage) Decision { return Ack },
+		WithKeySource(NewJWKS(ks.URL)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, pushRequest(tt.token))
+			if w.Code != tt.want {
+				t.Errorf("status: got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+// signWithKid gives token signed by new key of kid which is unknown to the other servers
+func signWithKid(t *testing.T, ks *keyServer, kid string) string {
+	t.Helper()
+	ks.addKey(t, kid)
+	return ks.sign(t, kid, testClaims(time.Now()))
+}
+
+func TestJWKS_Rotation(t *testing.T) {
+	ks := newKeyServer(t, "k1")
+	j := NewJWKS(ks.URL)
+	now := time.Now()
+	j.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := j.Key(ctx, "k1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := j.Key(ctx, "k1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&ks.fetches); n != 1 {
+		t.Errorf("fetches: got %d, want %d", n, 1)
+	}
+
+	// New key is fetched after the interval limiting refetch
+	ks.addKey(t, "k2")
+	if _, err := j.Key(ctx, "k2"); err == nil {
+		t.Error("got nil, want error before refetch interval")
+	}
+	now = now.Add(time.Minute)
+	if _, err := j.Key(ctx, "k2"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&ks.fetches); n != 2 {
+		t.Errorf("fetches: got %d, want %d", n, 2)
+	}
+}
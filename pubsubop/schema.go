@@ -0,0 +1,345 @@
+package pubsubop
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Attributes of schema validation
+const (
+	// SchemaVersionAttribute is attribute telling version of schema of the message
+	SchemaVersionAttribute = "schema-version"
+	// ValidationErrorAttribute is attribute having the error of message forwarded by DeadLetterPublisher
+	ValidationErrorAttribute = "validation-error"
+)
+
+// ErrUnknownSchemaVersion is returned when the topic has no schema of the version of message
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+// ValidationError tells where message doesn't match the schema
+type ValidationError struct {
+	Topic   string
+	Version string
+	// Path is JSON pointer of the invalid value such as /user/name. It is empty for the whole message.
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	msg := "schema " + e.Topic + " version " + e.Version + ": "
+	if e.Path != "" {
+		msg += e.Path + ": "
+	}
+	return msg + e.Reason
+}
+
+// Schema validates data of message
+type Schema interface {
+	// Validate checks data encoded in contentType. Error is *ValidationError when data doesn't match the schema.
+	Validate(contentType string, data []byte) error
+}
+
+// SchemaRegistry keeps versions of schemas of topics
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]map[string]Schema
+	latest  map[string]string
+}
+
+// NewSchemaRegistry creates empty SchemaRegistry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas: map[string]map[string]Schema{},
+		latest:  map[string]string{},
+	}
+}
+
+// Register adds the version of schema of the topic.
+// The version registered last is used for messages without SchemaVersionAttribute.
+func (r *SchemaRegistry) Register(topic, version string, s Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.schemas[topic] == nil {
+		r.schemas[topic] = map[string]Schema{}
+	}
+	r.schemas[topic][version] = s
+	r.latest[topic] = version
+}
+
+// Validate checks data against the version of schema of the topic, and gives the version used.
+// The latest version is used when version is empty, and nothing is checked when the topic has no schema.
+func (r *SchemaRegistry) Validate(topic, version, contentType string, data []byte) (string, error) {
+	r.mu.RLock()
+	versions := r.schemas[topic]
+	if version == "" {
+		version = r.latest[topic]
+	}
+	s, ok := versions[version]
+	r.mu.RUnlock()
+	if versions == nil {
+		return "", nil
+	}
+	if !ok {
+		return "", fmt.Errorf("%w %q of topic %s", ErrUnknownSchemaVersion, version, topic)
+	}
+	if err := s.Validate(contentType, data); err != nil {
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			verr = &ValidationError{Reason: err.Error()}
+		}
+		verr.Topic, verr.Version = topic, version
+		return version, verr
+	}
+	return version, nil
+}
+
+// WithSchemaRegistry validates messages of the topic by r.
+// Invalid messages fail to be published, and Consume gives them to the dead letter handler.
+func WithSchemaRegistry(r *SchemaRegistry) Option {
+	return func(mgr *Manager) {
+		mgr.schemas = r
+	}
+}
+
+// validate checks msg by the schema of the topic and sets SchemaVersionAttribute of the version used
+func (mgr *Manager) validate(msg Message) (Message, error) {
+	version, err := mgr.schemas.Validate(mgr.TopicName, msg.Attributes[SchemaVersionAttribute],
+		msg.Attributes[ContentTypeAttribute], msg.Data)
+	if err != nil || version == "" || msg.Attributes[SchemaVersionAttribute] != "" {
+		return msg, err
+	}
+	attrs := make(map[string]string, len(msg.Attributes)+1)
+	for k, v := range msg.Attributes {
+		attrs[k] = v
+	}
+	attrs[SchemaVersionAttribute] = version
+	msg.Attributes = attrs
+	return msg, nil
+}
+
+// JSONSchema validates JSON data by JSON Schema
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+// NewJSONSchema compiles JSON Schema
+func NewJSONSchema(schema string) (*JSONSchema, error) {
+	s, err := jsonschema.CompileString("schema.json", schema)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONSchema{schema: s}, nil
+}
+
+// Validate checks data which is JSON. The most nested problem is given as the error.
+func (s *JSONSchema) Validate(contentType string, data []byte) error {
+	if contentType != "" && contentType != ContentTypeJSON {
+		return &ValidationError{Reason: "content type " + contentType + " is not JSON"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return &ValidationError{Reason: "invalid JSON: " + err.Error()}
+	}
+	err := s.schema.Validate(v)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	for len(verr.Causes) > 0 {
+		verr = verr.Causes[0]
+	}
+	return &ValidationError{Path: verr.InstanceLocation, Reason: verr.Message}
+}
+
+// ProtoSchema validates data encoded as the protobuf message in binary or JSON format
+type ProtoSchema struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// NewProtoSchema creates ProtoSchema of the message descriptor
+func NewProtoSchema(desc protoreflect.MessageDescriptor) *ProtoSchema {
+	return &ProtoSchema{desc: desc}
+}
+
+// Validate checks data decodes into the message without unknown fields and with required fields.
+// Path of the error is empty when binary data fails to decode as it doesn't tell the field.
+func (s *ProtoSchema) Validate(contentType string, data []byte) error {
+	m := dynamicpb.NewMessage(s.desc)
+	var err error
+	switch contentType {
+	case "", ContentTypeProtobuf:
+		err = proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(data, m)
+	case ContentTypeJSON:
+		err = protojson.UnmarshalOptions{AllowPartial: true}.Unmarshal(data, m)
+	default:
+		return &ValidationError{Reason: "content type " + contentType + " is not protobuf"}
+	}
+	if err != nil {
+		verr := &ValidationError{Reason: strings.TrimPrefix(err.Error(), "proto: ")}
+		if contentType == ContentTypeJSON {
+			verr.Path = protojsonErrorPath(data, err)
+		}
+		return verr
+	}
+	return checkMessage(m, "")
+}
+
+// protojsonPosition matches position in error of protojson such as (line 1:9)
+var protojsonPosition = regexp.MustCompile(`\(line (\d+):(\d+)\)`)
+
+// protojsonErrorPath gives JSON pointer of the key or the value at position told by err of protojson
+func protojsonErrorPath(data []byte, err error) string {
+	m := protojsonPosition.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ""
+	}
+	line, _ := strconv.Atoi(m[1])
+	col, _ := strconv.Atoi(m[2])
+	// Column counts runes from 1
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return ""
+		}
+		offset += i + 1
+	}
+	for ; col > 1 && offset < len(data); col-- {
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return jsonPointerAt(data, offset)
+}
+
+// jsonPointerAt gives JSON pointer of the key or the value starting at offset of data
+func jsonPointerAt(data []byte, offset int) string {
+	type frame struct {
+		object bool
+		key    string
+		index  int
+		isKey  bool
+	}
+	var stack []*frame
+	pointer := func() string {
+		var b strings.Builder
+		for _, f := range stack {
+			b.WriteByte('/')
+			if f.object {
+				b.WriteString(jsonPointerEscaper.Replace(f.key))
+			} else {
+				b.WriteString(strconv.Itoa(f.index))
+			}
+		}
+		return b.String()
+	}
+	// next moves the parent to the next key or element after a value
+	next := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if f := stack[len(stack)-1]; f.object {
+			f.isKey = true
+		} else {
+			f.index++
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		end := int(dec.InputOffset())
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if end > offset {
+				return pointer()
+			}
+			stack = append(stack, &frame{object: tok == json.Delim('{'), isKey: true})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if end > offset {
+				return pointer()
+			}
+			next()
+		default:
+			if top != nil && top.object && top.isKey {
+				top.key, _ = tok.(string)
+				top.isKey = false
+				if end > offset {
+					return pointer()
+				}
+				continue
+			}
+			if end > offset {
+				return pointer()
+			}
+			next()
+		}
+	}
+}
+
+// jsonPointerEscaper escapes reference token of JSON pointer
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// checkMessage finds unknown fields and missing required fields of m at path
+func checkMessage(m protoreflect.Message, path string) error {
+	if len(m.GetUnknown()) > 0 {
+		return &ValidationError{Path: path, Reason: "unknown field of " + string(m.Descriptor().FullName())}
+	}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fpath := path + "/" + fd.TextName()
+		if fd.Cardinality() == protoreflect.Required && !m.Has(fd) {
+			return &ValidationError{Path: fpath, Reason: "required field is missing"}
+		}
+		if !m.Has(fd) {
+			continue
+		}
+		var err error
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				continue
+			}
+			m.Get(fd).Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				err = checkMessage(v.Message(), fpath+"/"+k.String())
+				return err == nil
+			})
+		case fd.IsList():
+			if fd.Message() == nil {
+				continue
+			}
+			l := m.Get(fd).List()
+			for j := 0; j < l.Len() && err == nil; j++ {
+				err = checkMessage(l.Get(j).Message(), fpath+"/"+strconv.Itoa(j))
+			}
+		case fd.Message() != nil:
+			err = checkMessage(m.Get(fd).Message(), fpath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
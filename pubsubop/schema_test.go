@@ -0,0 +1,264 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const tweetJSONSchema = `{
+	"type": "object",
+	"required": ["id", "text"],
+	"properties": {
+		"id": {"type": "integer"},
+		"text": {"type": "string", "maxLength": 280},
+		"user": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"name": {"type": "string", "minLength": 1}}
+		}
+	}
+}`
+
+func TestJSONSchema_Validate(t *testing.T) {
+	s, err := NewJSONSchema(tweetJSONSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		path        string
+		reason      string
+	}{
+		{name: "valid", data: `{"id": 1, "text": "hello", "user": {"name": "fckey"}}`},
+		{name: "type", contentType: ContentTypeJSON, data: `{"id": "1", "text": "hello"}`, path: "/id", reason: "expected integer"},
+		{name: "required", data: `{"id": 1}`, reason: "missing properties: 'text'"},
+		{name: "nested", data: `{"id": 1, "text": "hello", "user": {"name": ""}}`, path: "/user/name", reason: "length must be >= 1"},
+		{name: "not JSON", data: `{"id": 1`, reason: "invalid JSON"},
+		{name: "content type", contentType: ContentTypeProtobuf, data: `{}`, reason: "is not JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.contentType, []byte(tt.data))
+			checkValidationError(t, err, tt.path, tt.reason)
+		})
+	}
+}
+
+func checkValidationError(t *testing.T, err error, path, reason string) {
+	t.Helper()
+	if reason == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want *ValidationError", err)
+	}
+	if verr.Path != path || !strings.Contains(verr.Reason, reason) {
+		t.Errorf("got %q %q, want %q containing %q", verr.Path, verr.Reason, path, reason)
+	}
+}
+
+// tweetDescriptor gives descriptor of proto2 message Tweet having required fields
+func tweetDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	field := func(name string, n int32, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(n), Label: label.Enum(), Type: typ.Enum()}
+	}
+	user := field("user", 3, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	user.TypeName = proto.String(".test.User")
+	mentions := field("mentions", 4, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	mentions.TypeName = proto.String(".test.User")
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("tweet.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("User"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_LABEL_REQUIRED, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			}},
+			{Name: proto.String("Tweet"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_LABEL_REQUIRED, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				field("text", 2, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				user, mentions,
+			}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return fd.Messages().ByName("Tweet")
+}
+
+// protoData encodes JSON of the message in binary format
+func protoData(t *testing.T, desc protoreflect.MessageDescriptor, js string) []byte {
+	t.Helper()
+	m := dynamicpb.NewMessage(desc)
+	if err := (protojson.UnmarshalOptions{AllowPartial: true}).Unmarshal([]byte(js), m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return b
+}
+
+func TestProtoSchema_Validate(t *testing.T) {
+	desc := tweetDescriptor(t)
+	s := NewProtoSchema(desc)
+	unknown := protowire.AppendVarint(protowire.AppendTag(protoData(t, desc, `{"id": 1}`), 99, protowire.VarintType), 1)
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		path        string
+		reason      string
+	}{
+		{name: "valid", data: protoData(t, desc, `{"id": 1, "text": "hello", "user": {"name": "fckey"}}`)},
+		{name: "JSON", contentType: ContentTypeJSON, data: []byte(`{"id": 1, "mentions": [{"name": "a"}]}`)},
+		{name: "required", contentType: ContentTypeProtobuf, data: protoData(t, desc, `{"text": "hello"}`), path: "/id", reason: "required field is missing"},
+		{name: "nested", data: protoData(t, desc, `{"id": 1, "user": {}}`), path: "/user/name", reason: "required field is missing"},
+		{name: "list", data: protoData(t, desc, `{"id": 1, "mentions": [{"name": "a"}, {}]}`), path: "/mentions/1/name", reason: "required field is missing"},
+		{name: "unknown", data: unknown, reason: "unknown field of test.Tweet"},
+		{name: "JSON unknown", contentType: ContentTypeJSON, data: []byte(`{"id": 1, "lang": "en"}`), path: "/lang", reason: "unknown field"},
+		{name: "JSON nested unknown", contentType: ContentTypeJSON, data: []byte("{\"id\": 1,\n \"text\": \"héllo\", \"mentions\": [{\"name\": \"a\"}, {\"a/b\": 1}]}"),
+			path: "/mentions/1/a~1b", reason: "unknown field"},
+		{name: "JSON invalid value", contentType: ContentTypeJSON, data: []byte(`{"id": 1, "user": {"name": 2}}`), path: "/user/name", reason: "invalid value"},
+		{name: "JSON syntax", contentType: ContentTypeJSON, data: []byte(`{"id": `), reason: "unexpected EOF"},
+		{name: "content type", contentType: "avro/binary", data: []byte{}, reason: "is not protobuf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidationError(t, s.Validate(tt.contentType, tt.data), tt.path, tt.reason)
+		})
+	}
+}
+
+func newTestRegistry(t *testing.T) *SchemaRegistry {
+	t.Helper()
+	v1, err := NewJSONSchema(`{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v2, err := NewJSONSchema(tweetJSONSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := NewSchemaRegistry()
+	r.Register("tweets", "v1", v1)
+	r.Register("tweets", "v2", v2)
+	return r
+}
+
+func TestManager_PublishMessage_Schema(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "", WithClientOptions(opts...), WithSchemaRegistry(newTestRegistry(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	// The latest version is used without SchemaVersionAttribute
+	if _, err := mgr.PublishMessage(ctx, Message{Data: []byte(`{"id": 1, "text": "hello"}`)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = mgr.PublishMessage(ctx, Message{Data: []byte(`{"id": 2}`)})
+	checkValidationError(t, err, "", "missing properties: 'text'")
+	if !strings.HasPrefix(err.Error(), "schema tweets version v2: ") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := mgr.PublishMessage(ctx, Message{Data: []byte(`{"id": 3}`), Attributes: map[string]string{SchemaVersionAttribute: "v1"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = mgr.PublishMessage(ctx, Message{Data: []byte(`{"id": "4"}`), Attributes: map[string]string{SchemaVersionAttribute: "v1"}})
+	checkValidationError(t, err, "/id", "expected integer")
+	_, err = mgr.PublishMessage(ctx, Message{Data: []byte(`{"id": 5}`), Attributes: map[string]string{SchemaVersionAttribute: "v3"}})
+	if !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Errorf("got %v, want %v", err, ErrUnknownSchemaVersion)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 2 || msgs[0].Attributes[SchemaVersionAttribute] != "v2" || msgs[1].Attributes[SchemaVersionAttribute] != "v1" {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
+
+func TestManager_Consume_Schema(t *testing.T) {
+	srv, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-sub", WithClientOptions(opts...), WithSchemaRegistry(newTestRegistry(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if err := mgr.CreateSub(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dlq, err := NewManager(ctx, "test-project", "tweets-dead-letter", "", WithClient(mgr.client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer dlq.Close()
+
+	srv.Publish(testTopic, []byte(`{"id": 1, "text": "hello"}`), nil)
+	srv.Publish(testTopic, []byte(`{"id": "2"}`), map[string]string{SchemaVersionAttribute: "v1"})
+
+	var mu sync.Mutex
+	var got []string
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- mgr.Consume(ctx, func(ctx context.Context, msg *pubsub.Message) Decision {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, string(msg.Data))
+			return Ack
+		}, WithDeadLetterHandler(DeadLetterPublisher(dlq)))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= 1 && deadLettered(srv) != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != `{"id": 1, "text": "hello"}` {
+		t.Errorf("unexpected messages: %v", got)
+	}
+	dl := deadLettered(srv)
+	if dl.Attributes[ValidationErrorAttribute] != "schema tweets version v1: /id: expected integer, but got string" ||
+		dl.Attributes[SchemaVersionAttribute] != "v1" {
+		t.Errorf("unexpected dead letter: %+v", dl.Attributes)
+	}
+}
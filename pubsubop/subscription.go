@@ -0,0 +1,438 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Limits of subscription configuration of Pub/Sub
+const (
+	MinAckDeadline        = 10 * time.Second
+	MaxAckDeadline        = 600 * time.Second
+	MinDeliveryAttempts   = 5
+	MaxDeliveryAttempts   = 100
+	MaxRetryBackoff       = 600 * time.Second
+	MinRetentionDuration  = 10 * time.Minute
+	MaxRetentionDuration  = 7 * 24 * time.Hour
+	MinExpirationDuration = 24 * time.Hour
+)
+
+// DefaultAckDeadline is ack deadline of subscription created by CreateSub
+const DefaultAckDeadline = 30 * time.Second
+
+// Retry backoff of Pub/Sub when a bound is not set
+const (
+	defaultMinRetryBackoff = 10 * time.Second
+	defaultMaxRetryBackoff = 600 * time.Second
+)
+
+// defaultRetention is retention of Pub/Sub when it is not set
+const defaultRetention = 7 * 24 * time.Hour
+
+// ErrImmutableField is returned when UpdateSubscription needs to change field which can't be updated
+var ErrImmutableField = errors.New("field of subscription can't be updated")
+
+// ConfigError is returned when subscription configuration exceeds limits of Pub/Sub
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid subscription config: " + strings.Join(e.Problems, "; ")
+}
+
+// SubscriptionConfig is desired configuration of subscription.
+// Zero value of the field means the default of Pub/Sub.
+type SubscriptionConfig struct {
+	AckDeadline time.Duration
+	// DeadLetterTopic is ID of topic in the same project where undeliverable messages are forwarded
+	DeadLetterTopic     string
+	MaxDeliveryAttempts int
+	// MinRetryBackoff and MaxRetryBackoff delay redelivery of nacked messages.
+	// Messages are redelivered immediately when both are zero, and either of zero is default of Pub/Sub.
+	MinRetryBackoff     time.Duration
+	MaxRetryBackoff     time.Duration
+	RetentionDuration   time.Duration
+	RetainAckedMessages bool
+	// Expiration deletes the subscription after it is inactive for the duration
+	Expiration          time.Duration
+	NeverExpire         bool
+	Filter              string
+	ExactlyOnceDelivery bool
+	Labels              map[string]string
+	// PushEndpoint makes push subscription delivering messages to the HTTPS endpoint
+	PushEndpoint string
+	// PushServiceAccount is email of service account signing OIDC token sent with pushes
+	PushServiceAccount string
+	// PushAudience is audience of the OIDC token. Pub/Sub uses PushEndpoint when it is empty.
+	PushAudience string
+}
+
+// SubscriptionBuilder builds SubscriptionConfig in fluent manner.
+//
+//	cfg, err := pubsubop.NewSubscriptionBuilder().
+//		DeadLetter("tweets-dead-letter", 10).
+//		RetryBackoff(10*time.Second, 10*time.Minute).
+//		Filter(`attributes.lang = "en"`).
+//		Build()
+type SubscriptionBuilder struct {
+	cfg SubscriptionConfig
+}
+
+// NewSubscriptionBuilder starts SubscriptionConfig having DefaultAckDeadline
+func NewSubscriptionBuilder() *SubscriptionBuilder {
+	return &SubscriptionBuilder{cfg: SubscriptionConfig{AckDeadline: DefaultAckDeadline}}
+}
+
+// AckDeadline sets how long Pub/Sub waits for ack before redelivery
+func (b *SubscriptionBuilder) AckDeadline(d time.Duration) *SubscriptionBuilder {
+	b.cfg.AckDeadline = d
+	return b
+}
+
+// DeadLetter forwards messages to the topic after maxAttempts deliveries
+func (b *SubscriptionBuilder) DeadLetter(topic string, maxAttempts int) *SubscriptionBuilder {
+	b.cfg.DeadLetterTopic = topic
+	b.cfg.MaxDeliveryAttempts = maxAttempts
+	return b
+}
+
+// RetryBackoff delays redelivery exponentially between min and max
+func (b *SubscriptionBuilder) RetryBackoff(min, max time.Duration) *SubscriptionBuilder {
+	b.cfg.MinRetryBackoff = min
+	b.cfg.MaxRetryBackoff = max
+	return b
+}
+
+// Retention sets how long unacked messages are kept
+func (b *SubscriptionBuilder) Retention(d time.Duration) *SubscriptionBuilder {
+	b.cfg.RetentionDuration = d
+	return b
+}
+
+// RetainAcked keeps acked messages for the retention so that they can be replayed by seek
+func (b *SubscriptionBuilder) RetainAcked() *SubscriptionBuilder {
+	b.cfg.RetainAckedMessages = true
+	return b
+}
+
+// Expiration deletes the subscription after inactivity of d
+func (b *SubscriptionBuilder) Expiration(d time.Duration) *SubscriptionBuilder {
+	b.cfg.Expiration = d
+	b.cfg.NeverExpire = false
+	return b
+}
+
+// NeverExpire keeps the subscription even if it is inactive
+func (b *SubscriptionBuilder) NeverExpire() *SubscriptionBuilder {
+	b.cfg.Expiration = 0
+	b.cfg.NeverExpire = true
+	return b
+}
+
+// Filter delivers only messages matching expr such as `attributes.lang = "en"`
+func (b *SubscriptionBuilder) Filter(expr string) *SubscriptionBuilder {
+	b.cfg.Filter = expr
+	return b
+}
+
+// ExactlyOnce enables exactly-once delivery
+func (b *SubscriptionBuilder) ExactlyOnce() *SubscriptionBuilder {
+	b.cfg.ExactlyOnceDelivery = true
+	return b
+}
+
+// Label adds label of the subscription
+func (b *SubscriptionBuilder) Label(key, value string) *SubscriptionBuilder {
+	if b.cfg.Labels == nil {
+		b.cfg.Labels = map[string]string{}
+	}
+	b.cfg.Labels[key] = value
+	return b
+}
+
+// Push delivers messages to the endpoint with OIDC token of the service account for the audience
+func (b *SubscriptionBuilder) Push(endpoint, serviceAccount, audience string) *SubscriptionBuilder {
+	b.cfg.PushEndpoint = endpoint
+	b.cfg.PushServiceAccount = serviceAccount
+	b.cfg.PushAudience = audience
+	return b
+}
+
+// Build validates and gives the built SubscriptionConfig.
+// Error is *ConfigError when the config exceeds limits of Pub/Sub.
+func (b *SubscriptionBuilder) Build() (*SubscriptionConfig, error) {
+	cfg := b.cfg
+	if b.cfg.Labels != nil {
+		cfg.Labels = make(map[string]string, len(b.cfg.Labels))
+		for k, v := range b.cfg.Labels {
+			cfg.Labels[k] = v
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the config against limits of Pub/Sub.
+// Error is *ConfigError.
+func (c *SubscriptionConfig) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if c.AckDeadline != 0 && (c.AckDeadline < MinAckDeadline || c.AckDeadline > MaxAckDeadline) {
+		addf("ack deadline %v is out of [%v, %v]", c.AckDeadline, MinAckDeadline, MaxAckDeadline)
+	}
+	if c.DeadLetterTopic != "" && (c.MaxDeliveryAttempts < MinDeliveryAttempts || c.MaxDeliveryAttempts > MaxDeliveryAttempts) {
+		addf("max delivery attempts %d is out of [%d, %d]", c.MaxDeliveryAttempts, MinDeliveryAttempts, MaxDeliveryAttempts)
+	}
+	if c.DeadLetterTopic == "" && c.MaxDeliveryAttempts != 0 {
+		addf("max delivery attempts needs dead letter topic")
+	}
+	if c.MinRetryBackoff < 0 || c.MaxRetryBackoff < 0 || c.MinRetryBackoff > MaxRetryBackoff || c.MaxRetryBackoff > MaxRetryBackoff {
+		addf("retry backoff is out of [0, %v]", MaxRetryBackoff)
+	}
+	if c.MaxRetryBackoff != 0 && c.MinRetryBackoff > c.MaxRetryBackoff {
+		addf("min retry backoff %v exceeds max %v", c.MinRetryBackoff, c.MaxRetryBackoff)
+	}
+	if c.RetentionDuration != 0 && (c.RetentionDuration < MinRetentionDuration || c.RetentionDuration > MaxRetentionDuration) {
+		addf("retention %v is out of [%v, %v]", c.RetentionDuration, MinRetentionDuration, MaxRetentionDuration)
+	}
+	if c.Expiration != 0 {
+		if c.Expiration < MinExpirationDuration {
+			addf("expiration %v is shorter than %v", c.Expiration, MinExpirationDuration)
+		}
+		if c.Expiration < c.retention() {
+			addf("expiration %v is shorter than retention %v", c.Expiration, c.retention())
+		}
+	}
+	if c.PushEndpoint != "" {
+		if u, err := url.Parse(c.PushEndpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+			addf("push endpoint %q must be HTTPS URL", c.PushEndpoint)
+		}
+	}
+	if c.PushEndpoint == "" && (c.PushServiceAccount != "" || c.PushAudience != "") {
+		addf("push service account and audience need push endpoint")
+	}
+	if c.PushAudience != "" && c.PushServiceAccount == "" {
+		addf("push audience needs push service account")
+	}
+	for k := range c.Labels {
+		if k == "" {
+			addf("label key must not be empty")
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *SubscriptionConfig) retention() time.Duration {
+	if c.RetentionDuration == 0 {
+		return defaultRetention
+	}
+	return c.RetentionDuration
+}
+
+func (c *SubscriptionConfig) expiration() interface{} {
+	if c.NeverExpire {
+		return time.Duration(0)
+	}
+	if c.Expiration != 0 {
+		return c.Expiration
+	}
+	return nil
+}
+
+func (c *SubscriptionConfig) deadLetterPolicy(client *pubsub.Client) *pubsub.DeadLetterPolicy {
+	if c.DeadLetterTopic == "" {
+		return nil
+	}
+	return &pubsub.DeadLetterPolicy{
+		DeadLetterTopic:     client.Topic(c.DeadLetterTopic).String(),
+		MaxDeliveryAttempts: c.MaxDeliveryAttempts,
+	}
+}
+
+func (c *SubscriptionConfig) pushConfig() pubsub.PushConfig {
+	pc := pubsub.PushConfig{Endpoint: c.PushEndpoint}
+	if c.PushServiceAccount != "" {
+		pc.AuthenticationMethod = &pubsub.OIDCToken{
+			ServiceAccountEmail: c.PushServiceAccount,
+			Audience:            c.PushAudience,
+		}
+	}
+	return pc
+}
+
+func (c *SubscriptionConfig) retryPolicy() *pubsub.RetryPolicy {
+	if c.MinRetryBackoff == 0 && c.MaxRetryBackoff == 0 {
+		return nil
+	}
+	return &pubsub.RetryPolicy{MinimumBackoff: c.MinRetryBackoff, MaximumBackoff: c.MaxRetryBackoff}
+}
+
+// CreateSub creates subscription having DefaultAckDeadline
+func (mgr *Manager) CreateSub() error {
+	return mgr.CreateSubscription(context.Background(), &SubscriptionConfig{AckDeadline: DefaultAckDeadline})
+}
+
+// CreateSubscription creates subscription of the topic by cfg.
+// The topic and the dead letter topic are created if they don't exist.
+func (mgr *Manager) CreateSubscription(ctx context.Context, cfg *SubscriptionConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	t, err := mgr.getTopic(ctx)
+	if err != nil {
+		return err
+	}
+	if err := mgr.ensureDeadLetterTopic(ctx, cfg); err != nil {
+		return err
+	}
+	_, err = mgr.client.CreateSubscription(ctx, mgr.SubName, pubsub.SubscriptionConfig{
+		Topic:                     t,
+		PushConfig:                cfg.pushConfig(),
+		AckDeadline:               cfg.AckDeadline,
+		RetainAckedMessages:       cfg.RetainAckedMessages,
+		RetentionDuration:         cfg.RetentionDuration,
+		ExpirationPolicy:          cfg.expiration(),
+		Labels:                    cfg.Labels,
+		DeadLetterPolicy:          cfg.deadLetterPolicy(mgr.client),
+		Filter:                    cfg.Filter,
+		RetryPolicy:               cfg.retryPolicy(),
+		EnableExactlyOnceDelivery: cfg.ExactlyOnceDelivery,
+	})
+	return err
+}
+
+// UpdateSubscription changes the existing subscription to be cfg and reports whether it was changed.
+// Durations of zero and nil labels in cfg are left as they are, and the other fields are reconciled.
+// Push subscription is changed to pull when cfg has no push endpoint.
+// Error wraps ErrImmutableField when the filter differs.
+func (mgr *Manager) UpdateSubscription(ctx context.Context, cfg *SubscriptionConfig) (bool, error) {
+	if err := cfg.Validate(); err != nil {
+		return false, err
+	}
+	sub := mgr.client.Subscription(mgr.SubName)
+	cur, err := sub.Config(ctx)
+	if err != nil {
+		return false, err
+	}
+	if cur.Filter != cfg.Filter {
+		return false, fmt.Errorf("%w: filter %q to %q", ErrImmutableField, cur.Filter, cfg.Filter)
+	}
+
+	var upd pubsub.SubscriptionConfigToUpdate
+	changed := false
+	if want := cfg.pushConfig(); !samePushConfig(cur.PushConfig, want) {
+		upd.PushConfig = &want
+		changed = true
+	}
+	if cfg.AckDeadline != 0 && cur.AckDeadline != cfg.AckDeadline {
+		upd.AckDeadline = cfg.AckDeadline
+		changed = true
+	}
+	if cur.RetainAckedMessages != cfg.RetainAckedMessages {
+		upd.RetainAckedMessages = cfg.RetainAckedMessages
+		changed = true
+	}
+	if cfg.RetentionDuration != 0 && cur.RetentionDuration != cfg.RetentionDuration {
+		upd.RetentionDuration = cfg.RetentionDuration
+		changed = true
+	}
+	if exp := cfg.expiration(); exp != nil && durationOr(cur.ExpirationPolicy, 0) != durationOr(exp, 0) {
+		upd.ExpirationPolicy = exp
+		changed = true
+	}
+	if want := cfg.deadLetterPolicy(mgr.client); !reflect.DeepEqual(cur.DeadLetterPolicy, want) {
+		if want == nil {
+			want = &pubsub.DeadLetterPolicy{}
+		} else if err := mgr.ensureDeadLetterTopic(ctx, cfg); err != nil {
+			return false, err
+		}
+		upd.DeadLetterPolicy = want
+		changed = true
+	}
+	if want := cfg.retryPolicy(); !sameRetryPolicy(cur.RetryPolicy, want) {
+		if want == nil {
+			want = &pubsub.RetryPolicy{}
+		}
+		upd.RetryPolicy = want
+		changed = true
+	}
+	if cfg.Labels != nil && !sameLabels(cur.Labels, cfg.Labels) {
+		upd.Labels = cfg.Labels
+		changed = true
+	}
+	if cur.EnableExactlyOnceDelivery != cfg.ExactlyOnceDelivery {
+		upd.EnableExactlyOnceDelivery = cfg.ExactlyOnceDelivery
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	if _, err := sub.Update(ctx, upd); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (mgr *Manager) ensureDeadLetterTopic(ctx context.Context, cfg *SubscriptionConfig) error {
+	if cfg.DeadLetterTopic == "" {
+		return nil
+	}
+	_, err := mgr.createTopicIfNotExists(ctx, cfg.DeadLetterTopic)
+	return err
+}
+
+func samePushConfig(cur, want pubsub.PushConfig) bool {
+	if cur.Endpoint != want.Endpoint {
+		return false
+	}
+	curToken, _ := cur.AuthenticationMethod.(*pubsub.OIDCToken)
+	wantToken, _ := want.AuthenticationMethod.(*pubsub.OIDCToken)
+	if curToken == nil || wantToken == nil {
+		return curToken == nil && wantToken == nil
+	}
+	return *curToken == *wantToken
+}
+
+// sameRetryPolicy compares bounds of the policies taking bound not set as default of Pub/Sub
+func sameRetryPolicy(cur, want *pubsub.RetryPolicy) bool {
+	if cur == nil || want == nil {
+		return cur == nil && want == nil
+	}
+	return durationOr(cur.MinimumBackoff, defaultMinRetryBackoff) == durationOr(want.MinimumBackoff, defaultMinRetryBackoff) &&
+		durationOr(cur.MaximumBackoff, defaultMaxRetryBackoff) == durationOr(want.MaximumBackoff, defaultMaxRetryBackoff)
+}
+
+// durationOr returns the duration of optional d, or def when d is nil or zero
+func durationOr(d interface{}, def time.Duration) time.Duration {
+	if v, ok := d.(time.Duration); ok && v != 0 {
+		return v
+	}
+	return def
+}
+
+func sameLabels(cur, want map[string]string) bool {
+	if len(cur) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if cur[k] != v {
+			return false
+		}
+	}
+	return true
+}
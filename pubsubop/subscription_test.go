@@ -0,0 +1,209 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestSubscriptionBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *SubscriptionBuilder
+		want    string
+	}{
+		{name: "valid", builder: NewSubscriptionBuilder().DeadLetter("dlq", 5).RetryBackoff(time.Second, time.Minute).
+			Retention(time.Hour).Expiration(48*time.Hour).Label("team", "sandbox")},
+		{name: "ack deadline", builder: NewSubscriptionBuilder().AckDeadline(time.Second), want: "ack deadline 1s is out of"},
+		{name: "attempts", builder: NewSubscriptionBuilder().DeadLetter("dlq", 200), want: "max delivery attempts 200"},
+		{name: "backoff", builder: NewSubscriptionBuilder().RetryBackoff(time.Minute, time.Second), want: "min retry backoff 1m0s exceeds"},
+		{name: "retention", builder: NewSubscriptionBuilder().Retention(time.Minute), want: "retention 1m0s is out of"},
+		{name: "expiration", builder: NewSubscriptionBuilder().Expiration(48 * time.Hour), want: "shorter than retention"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.builder.Build()
+			if tt.want == "" {
+				if err != nil || cfg == nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var cerr *ConfigError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got %v, want *ConfigError", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_CreateSubscription(t *testing.T) {
+	_, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-sub", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	cfg, err := NewSubscriptionBuilder().
+		AckDeadline(time.Minute).
+		DeadLetter("tweets-dead-letter", 10).
+		RetryBackoff(10*time.Second, 10*time.Minute).
+		Retention(24*time.Hour).
+		RetainAcked().
+		NeverExpire().
+		Filter(`attributes.lang = "en"`).
+		ExactlyOnce().
+		Label("team", "sandbox").
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.CreateSubscription(ctx, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, err := mgr.client.Topic("tweets-dead-letter").Exists(ctx); err != nil || !ok {
+		t.Errorf("dead letter topic is not created: %v", err)
+	}
+
+	got, err := mgr.client.Subscription("tweets-sub").Config(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.AckDeadline != time.Minute || !got.RetainAckedMessages || got.RetentionDuration != 24*time.Hour ||
+		got.Filter != `attributes.lang = "en"` || !got.EnableExactlyOnceDelivery || got.Labels["team"] != "sandbox" {
+		t.Errorf("unexpected config: %+v", got)
+	}
+	if got.DeadLetterPolicy == nil || got.DeadLetterPolicy.DeadLetterTopic != "projects/test-project/topics/tweets-dead-letter" ||
+		got.DeadLetterPolicy.MaxDeliveryAttempts != 10 {
+		t.Errorf("unexpected dead letter policy: %+v", got.DeadLetterPolicy)
+	}
+	if got.RetryPolicy == nil || got.RetryPolicy.MinimumBackoff != 10*time.Second || got.RetryPolicy.MaximumBackoff != 10*time.Minute {
+		t.Errorf("unexpected retry policy: %+v", got.RetryPolicy)
+	}
+
+	// Nothing to change
+	if changed, err := mgr.UpdateSubscription(ctx, cfg); err != nil || changed {
+		t.Errorf("got %v, %v, want false, nil", changed, err)
+	}
+}
+
+func TestManager_UpdateSubscription(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	cfg, err := NewSubscriptionBuilder().
+		AckDeadline(time.Minute).
+		DeadLetter("tweets-dead-letter", 5).
+		RetryBackoff(time.Second, time.Minute).
+		Label("team", "sandbox").
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	changed, err := mgr.UpdateSubscription(ctx, cfg)
+	if err != nil || !changed {
+		t.Fatalf("got %v, %v, want true, nil", changed, err)
+	}
+	got, err := mgr.client.Subscription("tweets-sub").Config(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.AckDeadline != time.Minute || got.DeadLetterPolicy == nil || got.RetryPolicy == nil || got.Labels["team"] != "sandbox" {
+		t.Errorf("unexpected config: %+v", got)
+	}
+
+	// Dead letter and retry policy are removed
+	cfg, _ = NewSubscriptionBuilder().AckDeadline(time.Minute).Label("team", "sandbox").Build()
+	if changed, err := mgr.UpdateSubscription(ctx, cfg); err != nil || !changed {
+		t.Fatalf("got %v, %v, want true, nil", changed, err)
+	}
+	got, _ = mgr.client.Subscription("tweets-sub").Config(ctx)
+	if got.DeadLetterPolicy != nil || got.RetryPolicy != nil {
+		t.Errorf("policies are not removed: %+v, %+v", got.DeadLetterPolicy, got.RetryPolicy)
+	}
+
+	cfg, _ = NewSubscriptionBuilder().Filter(`attributes.lang = "en"`).Build()
+	if _, err := mgr.UpdateSubscription(ctx, cfg); !errors.Is(err, ErrImmutableField) {
+		t.Errorf("got %v, want %v", err, ErrImmutableField)
+	}
+}
+
+func TestSameRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		cur, want *pubsub.RetryPolicy
+		same      bool
+	}{
+		{name: "both nil", same: true},
+		{name: "removed", cur: &pubsub.RetryPolicy{MinimumBackoff: time.Second, MaximumBackoff: time.Minute}},
+		{name: "equal", cur: &pubsub.RetryPolicy{MinimumBackoff: time.Second, MaximumBackoff: time.Minute},
+			want: &pubsub.RetryPolicy{MinimumBackoff: time.Second, MaximumBackoff: time.Minute}, same: true},
+		{name: "default min", cur: &pubsub.RetryPolicy{MinimumBackoff: 10 * time.Second, MaximumBackoff: time.Minute},
+			want: &pubsub.RetryPolicy{MinimumBackoff: time.Duration(0), MaximumBackoff: time.Minute}, same: true},
+		{name: "default max", cur: &pubsub.RetryPolicy{MinimumBackoff: time.Second, MaximumBackoff: 600 * time.Second},
+			want: &pubsub.RetryPolicy{MinimumBackoff: time.Second}, same: true},
+		{name: "changed min", cur: &pubsub.RetryPolicy{MinimumBackoff: 10 * time.Second, MaximumBackoff: time.Minute},
+			want: &pubsub.RetryPolicy{MinimumBackoff: time.Second, MaximumBackoff: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameRetryPolicy(tt.cur, tt.want); got != tt.same {
+				t.Errorf("got %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestManager_CreateSubscription_Push(t *testing.T) {
+	_, opts := newTestServer(t)
+	ctx := context.Background()
+	mgr, err := NewManager(ctx, "test-project", "tweets", "tweets-push", WithClientOptions(opts...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mgr.Close()
+
+	if _, err := NewSubscriptionBuilder().Push("http://crunsample.run.app", "", "").Build(); err == nil {
+		t.Error("got nil, want error of non HTTPS endpoint")
+	}
+	cfg, err := NewSubscriptionBuilder().
+		Push("https://crunsample.run.app/pubsub/push", testServiceAccount, testAudience).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.CreateSubscription(ctx, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := mgr.client.Subscription("tweets-push").Config(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token, ok := got.PushConfig.AuthenticationMethod.(*pubsub.OIDCToken)
+	if got.PushConfig.Endpoint != "https://crunsample.run.app/pubsub/push" || !ok ||
+		token.ServiceAccountEmail != testServiceAccount || token.Audience != testAudience {
+		t.Errorf("unexpected push config: %+v", got.PushConfig)
+	}
+	if changed, err := mgr.UpdateSubscription(ctx, cfg); err != nil || changed {
+		t.Errorf("got %v, %v, want false, nil", changed, err)
+	}
+
+	// Changed to pull
+	cfg, _ = NewSubscriptionBuilder().Build()
+	if changed, err := mgr.UpdateSubscription(ctx, cfg); err != nil || !changed {
+		t.Fatalf("got %v, %v, want true, nil", changed, err)
+	}
+	got, _ = mgr.client.Subscription("tweets-push").Config(ctx)
+	if got.PushConfig.Endpoint != "" {
+		t.Errorf("unexpected push config: %+v", got.PushConfig)
+	}
+}
@@ -0,0 +1,181 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// DecodeErrorAttribute is attribute having the error of message forwarded by DeadLetterPublisher
+const DecodeErrorAttribute = "decode-error"
+
+// ErrUnknownContentType is returned when no codec is given for content type of message
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// Publisher publishes values of T encoded by the codec
+type Publisher[T any] struct {
+	mgr   *Manager
+	codec Codec
+}
+
+// NewPublisher creates Publisher to the topic of mgr
+func NewPublisher[T any](mgr *Manager, codec Codec) *Publisher[T] {
+	return &Publisher[T]{mgr: mgr, codec: codec}
+}
+
+// Message encodes v into Message having ContentTypeAttribute.
+// Attributes and OrderingKey can be added to it before PublishMessage of Manager.
+func (p *Publisher[T]) Message(v T) (Message, error) {
+	data, err := p.codec.Marshal(v)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to encode %T: %w", v, err)
+	}
+	return Message{
+		Data:       data,
+		Attributes: map[string]string{ContentTypeAttribute: p.codec.ContentType()},
+	}, nil
+}
+
+// Publish encodes and publishes v, and gives the message ID
+func (p *Publisher[T]) Publish(ctx context.Context, v T) (string, error) {
+	msg, err := p.Message(v)
+	if err != nil {
+		return "", err
+	}
+	return p.mgr.PublishMessage(ctx, msg)
+}
+
+// PublishBatch encodes and publishes vs concurrently.
+// ID of the value failed is empty and error is *BatchError when any of them failed.
+func (p *Publisher[T]) PublishBatch(ctx context.Context, vs []T) ([]string, error) {
+	msgs := make([]Message, 0, len(vs))
+	errs := map[int]error{}
+	idx := make([]int, 0, len(vs))
+	for i, v := range vs {
+		msg, err := p.Message(v)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		msgs = append(msgs, msg)
+		idx = append(idx, i)
+	}
+	published, err := p.mgr.PublishBatch(ctx, msgs)
+	var berr *BatchError
+	if err != nil && !errors.As(err, &berr) {
+		return nil, err
+	}
+	ids := make([]string, len(vs))
+	for j, i := range idx {
+		ids[i] = published[j]
+		if berr != nil && berr.Errors[j] != nil {
+			errs[i] = berr.Errors[j]
+		}
+	}
+	if len(errs) > 0 {
+		return ids, &BatchError{Errors: errs}
+	}
+	return ids, nil
+}
+
+// TypedHandler processes value decoded from msg
+type TypedHandler[T any] func(ctx context.Context, v T, msg *pubsub.Message) Decision
+
+// DeadLetterFunc handles message which couldn't be decoded or validated.
+// The message is acked when it returns nil, otherwise nacked.
+type DeadLetterFunc func(ctx context.Context, msg *pubsub.Message, err error) error
+
+// SubscriberOption configures Subscriber
+type SubscriberOption func(*subscriberConfig)
+
+type subscriberConfig struct {
+	codecs     map[string]Codec
+	deadLetter DeadLetterFunc
+}
+
+// WithCodecs accepts messages encoded by the codecs in addition to the one given to NewSubscriber
+func WithCodecs(codecs ...Codec) SubscriberOption {
+	return func(c *subscriberConfig) {
+		for _, codec := range codecs {
+			c.codecs[codec.ContentType()] = codec
+		}
+	}
+}
+
+// WithDeadLetter sets handler of messages which couldn't be decoded or validated.
+// Such messages are nacked without it.
+func WithDeadLetter(f DeadLetterFunc) SubscriberOption {
+	return func(c *subscriberConfig) {
+		c.deadLetter = f
+	}
+}
+
+// Subscriber consumes messages of the subscription as values of T
+type Subscriber[T any] struct {
+	mgr   *Manager
+	codec Codec
+	cfg   subscriberConfig
+}
+
+// NewSubscriber creates Subscriber of the subscription of mgr.
+// Message is decoded by codec of its ContentTypeAttribute, and by codec when it has no content type.
+func NewSubscriber[T any](mgr *Manager, codec Codec, opts ...SubscriberOption) *Subscriber[T] {
+	s := &Subscriber[T]{
+		mgr:   mgr,
+		codec: codec,
+		cfg:   subscriberConfig{codecs: map[string]Codec{codec.ContentType(): codec}},
+	}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	return s
+}
+
+// Decode gives value of data encoded in contentType
+func (s *Subscriber[T]) Decode(contentType string, data []byte) (T, error) {
+	var v T
+	codec := s.codec
+	if contentType != "" {
+		var ok bool
+		if codec, ok = s.cfg.codecs[contentType]; !ok {
+			return v, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+		}
+	}
+	if err := codec.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode %T: %w", v, err)
+	}
+	return v, nil
+}
+
+// Consume receives messages and gives them to h after decoding.
+// Messages which couldn't be decoded or validated are given to the dead letter handler.
+func (s *Subscriber[T]) Consume(ctx context.Context, h TypedHandler[T], opts ...ConsumeOption) error {
+	return s.mgr.Consume(ctx, func(ctx context.Context, msg *pubsub.Message) Decision {
+		v, err := s.Decode(msg.Attributes[ContentTypeAttribute], msg.Data)
+		if err != nil {
+			return deadLetter(ctx, s.cfg.deadLetter, msg, err)
+		}
+		return h(ctx, v, msg)
+	}, append([]ConsumeOption{WithDeadLetterHandler(s.cfg.deadLetter)}, opts...)...)
+}
+
+// DeadLetterPublisher gives DeadLetterFunc forwarding messages to the topic of dlq.
+// Error is set to ValidationErrorAttribute when the message is invalid for the schema, otherwise DecodeErrorAttribute.
+func DeadLetterPublisher(dlq *Manager) DeadLetterFunc {
+	return func(ctx context.Context, msg *pubsub.Message, err error) error {
+		attrs := make(map[string]string, len(msg.Attributes)+1)
+		for k, v := range msg.Attributes {
+			attrs[k] = v
+		}
+		key := DecodeErrorAttribute
+		var verr *ValidationError
+		if errors.As(err, &verr) || errors.Is(err, ErrUnknownSchemaVersion) {
+			key = ValidationErrorAttribute
+		}
+		attrs[key] = err.Error()
+		_, perr := dlq.PublishMessage(ctx, Message{Data: msg.Data, Attributes: attrs})
+		return perr
+	}
+}
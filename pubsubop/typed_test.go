@@ -0,0 +1,139 @@
+package pubsubop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type tweet struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := NewPublisher[tweet](mgr, JSONCodec{}).Publish(ctx, tweet{ID: 1, Text: "hello"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewPublisher[*wrapperspb.StringValue](mgr, ProtoCodec{}).Publish(ctx, wrapperspb.String("hello")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	if string(msgs[0].Data) != `{"id":1,"text":"hello"}` || msgs[0].Attributes[ContentTypeAttribute] != ContentTypeJSON {
+		t.Errorf("unexpected message: %+v", msgs[0])
+	}
+	if msgs[1].Attributes[ContentTypeAttribute] != ContentTypeProtobuf {
+		t.Errorf("unexpected message: %+v", msgs[1])
+	}
+
+	// Value which is not proto.Message is not published
+	ids, err := NewPublisher[tweet](mgr, ProtoCodec{}).PublishBatch(ctx, []tweet{{ID: 2}})
+	var berr *BatchError
+	if !errors.As(err, &berr) || berr.Errors[0] == nil || ids[0] != "" {
+		t.Errorf("got %v, %v, want *BatchError", ids, err)
+	}
+}
+
+func TestSubscriber_Decode(t *testing.T) {
+	_, mgr := newTestManager(t)
+	pub := NewPublisher[*wrapperspb.StringValue](mgr, ProtoCodec{})
+	msg, err := pub.Message(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sub := NewSubscriber[*wrapperspb.StringValue](mgr, ProtoCodec{})
+	v, err := sub.Decode(msg.Attributes[ContentTypeAttribute], msg.Data)
+	if err != nil || v.GetValue() != "hello" {
+		t.Errorf("got %v, %v, want hello", v, err)
+	}
+	if _, err := sub.Decode(ContentTypeJSON, []byte(`"hello"`)); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("got %v, want %v", err, ErrUnknownContentType)
+	}
+}
+
+func TestSubscriber_Consume(t *testing.T) {
+	srv, mgr := newTestManager(t)
+	ctx := context.Background()
+	dlq, err := NewManager(ctx, "test-project", "tweets-dead-letter", "", WithClient(mgr.client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer dlq.Close()
+
+	pub := NewPublisher[tweet](mgr, JSONCodec{})
+	if _, err := pub.Publish(ctx, tweet{ID: 1, Text: "hello"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Publish(testTopic, []byte("not json"), map[string]string{ContentTypeAttribute: ContentTypeJSON})
+	// Message without content type is decoded by the codec of Subscriber
+	srv.Publish(testTopic, []byte(`{"id":2,"text":"world"}`), nil)
+
+	var mu sync.Mutex
+	var got []tweet
+	sub := NewSubscriber[tweet](mgr, JSONCodec{}, WithDeadLetter(DeadLetterPublisher(dlq)))
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- sub.Consume(ctx, func(ctx context.Context, v tweet, msg *pubsub.Message) Decision {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, v)
+			return Ack
+		})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= 2 && deadLettered(srv) != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 || got[0].ID+got[1].ID != 3 {
+		t.Errorf("unexpected values: %v", got)
+	}
+	dl := deadLettered(srv)
+	if string(dl.Data) != "not json" || dl.Attributes[DecodeErrorAttribute] == "" ||
+		dl.Attributes[ContentTypeAttribute] != ContentTypeJSON {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+	for _, m := range srv.Messages() {
+		if m.Topic == testTopic && m.Acks == 0 {
+			t.Errorf("message is not acked: %s", m.Data)
+		}
+	}
+}
+
+// deadLettered gives message forwarded to the dead letter topic
+func deadLettered(srv *pstest.Server) *pstest.Message {
+	for _, m := range srv.Messages() {
+		if m.Topic == "projects/test-project/topics/tweets-dead-letter" {
+			return m
+		}
+	}
+	return nil
+}
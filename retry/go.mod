@@ -1,6 +1,6 @@
 module github.com/fckey/go-sandbox/retry
 
-go 1.12
+go 1.13
 
 require (
 	github.com/googleapis/gax-go/v2 v2.0.5
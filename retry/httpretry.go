@@ -25,28 +25,31 @@ func (i IsHTTPRequestRetryable) IsRetryableStatus(resp *http.Response, err error
 func RunWithHTTPRetry(ctx context.Context, config Config,
 	call RetryableHTTPRequest, checker IsHTTPRequestRetryable) (resp *http.Response, err error) {
 
-	return resp, Retry(ctx, config, func() (stop bool, err error) {
+	return resp, retryWithSpan(ctx, config, func(_ context.Context, span Span) (stop bool, err error) {
 		resp, err = call()
+		setHTTPStatus(span, resp)
 		if err == nil {
 			return true, nil
 		}
 		return !checker(resp, err), err
-	})
+	}, sleep)
 }
 
 // DoWithRetry executes http.Client.Do(http.Request) of given http.Client and http.Request as retryable manner
 // If simple Do is required, this function should be used.
+// The request is sent with the context of each attempt.
 // statuses is expected as list of StatusCode in http
 func DoWithRetry(ctx context.Context, cfg Config,
 	c *http.Client, r *http.Request, statuses ...int) (resp *http.Response, err error) {
 	checker := WithRtriableHTTPResponse(statuses...)
-	return resp, Retry(ctx, cfg, func() (stop bool, err error) {
-		resp, err = c.Do(r)
+	return resp, retryWithSpan(ctx, cfg, func(ctx context.Context, span Span) (stop bool, err error) {
+		resp, err = c.Do(r.WithContext(ctx))
+		setHTTPStatus(span, resp)
 		if err == nil && resp.StatusCode < 400 {
 			return true, nil
 		}
 		return !checker(resp, err), err
-	})
+	}, sleep)
 }
 
 // WithRtriableHTTPResponse judges if response is retriable or not
@@ -76,3 +79,14 @@ func WithRtriableHTTPResponse(statuses ...int) IsHTTPRequestRetryable {
 		return false
 	}
 }
+
+// setHTTPStatus records status code of resp to the span of the attempt
+func setHTTPStatus(span Span, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	span.SetAttributes(Attribute{Key: AttrHTTPStatus, Value: resp.StatusCode})
+	if class := httpErrorClass(resp.StatusCode); class != "" {
+		span.SetAttributes(Attribute{Key: AttrErrorClass, Value: class})
+	}
+}
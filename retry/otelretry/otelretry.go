@@ -0,0 +1,70 @@
+package otelretry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is an adapter of OpenTelemetry trace.Tracer to retry.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer wraps t to be given to retry.Config
+func NewTracer(t trace.Tracer) *Tracer {
+	return &Tracer{tracer: t}
+}
+
+// Start implements retry.Tracer
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, retry.Span) {
+	ctx, s := t.tracer.Start(ctx, name)
+	return ctx, &span{span: s}
+}
+
+// span is an adapter of trace.Span to retry.Span
+type span struct {
+	span trace.Span
+}
+
+func (s *span) SetAttributes(attrs ...retry.Attribute) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, keyValue(a))
+	}
+	s.span.SetAttributes(kvs...)
+}
+
+func (s *span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *span) End() {
+	s.span.End()
+}
+
+// keyValue converts retry.Attribute to attribute.KeyValue
+func keyValue(a retry.Attribute) attribute.KeyValue {
+	k := attribute.Key(a.Key)
+	switch v := a.Value.(type) {
+	case string:
+		return k.String(v)
+	case bool:
+		return k.Bool(v)
+	case int:
+		return k.Int(v)
+	case int64:
+		return k.Int64(v)
+	case float64:
+		return k.Float64(v)
+	case time.Duration:
+		return k.Int64(v.Milliseconds())
+	}
+	return k.String(fmt.Sprint(a.Value))
+}
@@ -0,0 +1,58 @@
+package otelretry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	cfg := retry.NewConfig(time.Microsecond, time.Millisecond, 2, 3).
+		WithTracer(NewTracer(tp.Tracer("otelretry_test")))
+
+	n := 0
+	err := retry.Retry(context.Background(), cfg, func() (bool, error) {
+		n++
+		if n < 2 {
+			return false, errors.New("retriable")
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("spans: got %d, want %d", len(spans), 3)
+	}
+	parent := spans[2]
+	if parent.Name() != retry.RetrySpanName {
+		t.Errorf("name: got %s, want %s", parent.Name(), retry.RetrySpanName)
+	}
+	for _, s := range spans[:2] {
+		if s.Name() != retry.AttemptSpanName {
+			t.Errorf("name: got %s, want %s", s.Name(), retry.AttemptSpanName)
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Error("attempt span is not child of retry span")
+		}
+	}
+	attrs := map[string]interface{}{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if got := attrs[retry.AttrAttempt]; got != int64(1) {
+		t.Errorf("attempt: got %v, want %d", got, 1)
+	}
+	if got := attrs[retry.AttrErrorClass]; got != retry.ErrorClassOther {
+		t.Errorf("error class: got %v, want %s", got, retry.ErrorClassOther)
+	}
+}
@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -13,34 +14,80 @@ type retryIter func() (stop bool, err error)
 // When retryIter's first return value is true, Retry immediately returns with retryIter's value in err.
 // When the provided context is done, Retry returns with an error that
 // includes both ctx.Error() and the last error returned by retryIter.
+// The last error is wrapped so that errors.Is and errors.As find it after retries run out.
+// When cfg.Tracer is set, the call is traced as a span having a child span per attempt.
 func Retry(ctx context.Context, cfg Config, f retryIter) error {
 	return retry(ctx, cfg, f, sleep)
 }
 
 func retry(ctx context.Context, cfg Config, f retryIter, s Sleep) error {
+	return retryWithSpan(ctx, cfg, func(context.Context, Span) (bool, error) { return f() }, s)
+}
+
+// RetryContext is Retry giving f the context of current attempt.
+// Spans started by f with the context are nested under the span of the attempt.
+func RetryContext(ctx context.Context, cfg Config, f func(ctx context.Context) (stop bool, err error)) error {
+	return retryWithSpan(ctx, cfg, func(ctx context.Context, _ Span) (bool, error) { return f(ctx) }, sleep)
+}
+
+// attemptIter is retryIter which is given the context and can annotate the span of current attempt
+type attemptIter func(ctx context.Context, span Span) (stop bool, err error)
+
+// retryWithSpan is the body of Retry.
+// It starts a span for whole call and a child span for each attempt with cfg.Tracer.
+func retryWithSpan(ctx context.Context, cfg Config, f attemptIter, s Sleep) (err error) {
+	tracer := cfg.Tracer
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+	ctx, span := tracer.Start(ctx, RetrySpanName)
+	span.SetAttributes(Attribute{Key: AttrMaxRetry, Value: cfg.MaxRetry})
+	defer func() {
+		span.SetAttributes(Attribute{Key: AttrAttempts, Value: cfg.count})
+		if err != nil {
+			span.SetAttributes(Attribute{Key: AttrErrorClass, Value: errorClass(err)})
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
 	var lastErr error
 	for {
 		cfg.count++
-		stop, err := f()
+		actx, aspan := tracer.Start(ctx, AttemptSpanName)
+		aspan.SetAttributes(Attribute{Key: AttrAttempt, Value: cfg.count})
+		stop, err := f(actx, aspan)
+		if err != nil {
+			aspan.SetAttributes(Attribute{Key: AttrErrorClass, Value: errorClass(err)})
+			aspan.RecordError(err)
+		}
 		if stop {
+			aspan.End()
 			return err
 		}
 		// Remember the last error from f.
 		if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
 			lastErr = err
 		}
-		p := cfg.Backoff.Pause()
-		if cerr := s(ctx, p); cerr != nil {
+		// No delay after the last attempt
+		if cfg.count >= cfg.MaxRetry {
+			aspan.End()
 			if lastErr != nil {
-				return fmt.Errorf("retry failed with %v; last error: %v", cerr, lastErr)
+				return fmt.Errorf("maximum traial exceeded; last error: %w", lastErr)
 			}
-			return cerr
+			return fmt.Errorf("operation was not succeded withnin %d trial", cfg.MaxRetry)
 		}
-		if cfg.count >= cfg.MaxRetry {
+		p := cfg.Backoff.Pause()
+		if d, ok := retryDelay(err); ok && d > p {
+			p = d
+		}
+		aspan.SetAttributes(Attribute{Key: AttrDelay, Value: p.Milliseconds()})
+		aspan.End()
+		if cerr := s(ctx, p); cerr != nil {
 			if lastErr != nil {
-				return fmt.Errorf("maximum traial exceeded; last error: %v", lastErr)
+				return fmt.Errorf("retry failed with %v; last error: %w", cerr, lastErr)
 			}
-			return fmt.Errorf("operation was not succeded withnin %d trial", cfg.MaxRetry)
+			return cerr
 		}
 	}
 }
@@ -61,6 +108,21 @@ func sleep(ctx context.Context, d time.Duration) error {
 	}
 }
 
+// RetryDelayer is implemented by error which knows how long to wait before next trial
+// such as error made from Retry-After header. Retry waits the delay when it's longer than backoff.
+type RetryDelayer interface {
+	RetryDelay() time.Duration
+}
+
+// retryDelay finds RetryDelayer in err
+func retryDelay(err error) (time.Duration, bool) {
+	var d RetryDelayer
+	if err == nil || !errors.As(err, &d) {
+		return 0, false
+	}
+	return d.RetryDelay(), true
+}
+
 // Is retryable is an interface to check if given error is retriable or not
 type RetriableChecker interface {
 	IsRetryableError(err error) bool
@@ -8,9 +8,11 @@ import (
 
 // Config is wrapper of gax.Config.
 // This can encapsulate gax setting in this module
+// Tracer is optional and Retry is not traced when it's nil.
 type Config struct {
 	gax.Backoff
 	MaxRetry int
+	Tracer   Tracer
 	count    int
 }
 
@@ -33,3 +35,9 @@ func NewConfig(initDuration time.Duration, maxDuration time.Duration, multiplier
 func DefaultBackoff() Config {
 	return NewConfig(100*time.Millisecond, 30000*time.Millisecond, 1.3, 10)
 }
+
+// WithTracer gives copy of the setting which traces Retry by t
+func (c Config) WithTracer(t Tracer) Config {
+	c.Tracer = t
+	return c
+}
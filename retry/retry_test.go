@@ -79,3 +79,50 @@ func TestRunWithRetry(t *testing.T) {
 		t.Error("got nil, want error")
 	}
 }
+
+type delayErr time.Duration
+
+func (d delayErr) Error() string             { return "delayed" }
+func (d delayErr) RetryDelay() time.Duration { return time.Duration(d) }
+
+func TestRetry_RetryDelay(t *testing.T) {
+	ctx := context.Background()
+	var pauses []time.Duration
+	n := 0
+	err := retry(ctx, NewConfig(time.Millisecond, time.Millisecond, 1, 3),
+		func() (bool, error) {
+			n++
+			if n < 2 {
+				return false, delayErr(time.Minute)
+			}
+			return true, nil
+		},
+		func(_ context.Context, d time.Duration) error {
+			pauses = append(pauses, d)
+			return nil
+		})
+	if err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if len(pauses) != 1 || pauses[0] != time.Minute {
+		t.Errorf("pauses: got %v, want [%v]", pauses, time.Minute)
+	}
+}
+
+func TestRetry_WrapsLastError(t *testing.T) {
+	ctx := context.Background()
+	err := retry(ctx, NewConfig(time.Millisecond, time.Millisecond, 1, 3),
+		func() (bool, error) { return false, delayErr(0) },
+		func(context.Context, time.Duration) error { return nil })
+	var d delayErr
+	if !errors.As(err, &d) {
+		t.Errorf("got %v, want delayErr after retries run out", err)
+	}
+
+	err = retry(ctx, NewConfig(time.Millisecond, time.Millisecond, 1, 3),
+		func() (bool, error) { return false, delayErr(0) },
+		func(context.Context, time.Duration) error { return context.Canceled })
+	if !errors.As(err, &d) {
+		t.Errorf("got %v, want delayErr after cancel", err)
+	}
+}
@@ -0,0 +1,174 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Span names used by Retry
+const (
+	RetrySpanName   = "retry.Retry"
+	AttemptSpanName = "retry.attempt"
+)
+
+// Attribute keys set on spans by Retry
+const (
+	AttrAttempt    = "retry.attempt"
+	AttrAttempts   = "retry.attempts"
+	AttrMaxRetry   = "retry.max_retry"
+	AttrDelay      = "retry.delay_ms"
+	AttrErrorClass = "retry.error_class"
+	AttrHTTPStatus = "http.status_code"
+)
+
+// Error classes set as AttrErrorClass
+const (
+	ErrorClassCanceled    = "canceled"
+	ErrorClassDeadline    = "deadline_exceeded"
+	ErrorClassTimeout     = "timeout"
+	ErrorClassNetwork     = "network"
+	ErrorClassClientError = "client_error"
+	ErrorClassServerError = "server_error"
+	ErrorClassOther       = "error"
+)
+
+// Attribute is key and value attached to a Span.
+// Value is expected to be one of string, bool, int, int64, float64 or time.Duration
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a unit of traced work such as whole Retry call or single attempt
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts Span. Adapter of tracing library such as OpenTelemetry should implement this.
+// Returned context is expected to carry the started span so that child span can be nested.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// noopTracer is used when Config.Tracer is not set
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
+
+// errorClass categorizes err to be recorded as AttrErrorClass
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassDeadline
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		if nerr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	return ErrorClassOther
+}
+
+// httpErrorClass categorizes HTTP status to be recorded as AttrErrorClass
+func httpErrorClass(status int) string {
+	switch {
+	case status >= 500:
+		return ErrorClassServerError
+	case status >= 400:
+		return ErrorClassClientError
+	}
+	return ""
+}
+
+// RecordedSpan is a span kept by Recorder
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+	Ended      bool
+}
+
+// Recorder is in-memory Tracer to inspect spans in tests
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+type recorderKey struct{}
+
+// Start implements Tracer
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &RecordedSpan{
+		Name:       name,
+		Attributes: map[string]interface{}{},
+		Start:      time.Now(),
+	}
+	if p, ok := ctx.Value(recorderKey{}).(*RecordedSpan); ok {
+		s.Parent = p
+	}
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return context.WithValue(ctx, recorderKey{}, s), &recordingSpan{r: r, s: s}
+}
+
+// Spans returns copy of the spans recorded so far in started order
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]RecordedSpan, 0, len(r.spans))
+	for _, s := range r.spans {
+		spans = append(spans, *s)
+	}
+	return spans
+}
+
+// recordingSpan is Span given by Recorder
+type recordingSpan struct {
+	r *Recorder
+	s *RecordedSpan
+}
+
+func (rs *recordingSpan) SetAttributes(attrs ...Attribute) {
+	rs.r.mu.Lock()
+	defer rs.r.mu.Unlock()
+	for _, a := range attrs {
+		rs.s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (rs *recordingSpan) RecordError(err error) {
+	rs.r.mu.Lock()
+	defer rs.r.mu.Unlock()
+	rs.s.Errors = append(rs.s.Errors, err)
+}
+
+func (rs *recordingSpan) End() {
+	rs.r.mu.Lock()
+	defer rs.r.mu.Unlock()
+	rs.s.End = time.Now()
+	rs.s.Ended = true
+}
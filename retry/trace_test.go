@@ -0,0 +1,141 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryTrace(t *testing.T) {
+	ctx := context.Background()
+	rec := &Recorder{}
+	n := 0
+	middleRetry := errors.New("middle retry")
+	err := retry(ctx, DefaultBackoff().WithTracer(rec),
+		func() (bool, error) {
+			n++
+			if n < 3 {
+				return false, middleRetry
+			}
+			return true, nil
+		},
+		func(context.Context, time.Duration) error { return nil })
+	if err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	spans := rec.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("spans: got %d, want %d", len(spans), 4)
+	}
+	parent := spans[0]
+	if parent.Name != RetrySpanName || !parent.Ended {
+		t.Errorf("unexpected parent span: %+v", parent)
+	}
+	if got := parent.Attributes[AttrAttempts]; got != 3 {
+		t.Errorf("attempts: got %v, want %d", got, 3)
+	}
+	for i, s := range spans[1:] {
+		if s.Name != AttemptSpanName || s.Parent == nil || s.Parent.Name != RetrySpanName || !s.Ended {
+			t.Errorf("unexpected attempt span: %+v", s)
+		}
+		if got := s.Attributes[AttrAttempt]; got != i+1 {
+			t.Errorf("attempt: got %v, want %d", got, i+1)
+		}
+	}
+	if got := spans[1].Attributes[AttrErrorClass]; got != ErrorClassOther {
+		t.Errorf("error class: got %v, want %s", got, ErrorClassOther)
+	}
+	if _, ok := spans[1].Attributes[AttrDelay]; !ok {
+		t.Error("delay is not recorded on failed attempt")
+	}
+	if _, ok := spans[3].Attributes[AttrDelay]; ok {
+		t.Error("delay is recorded on last attempt")
+	}
+}
+
+func TestDoWithRetryTrace(t *testing.T) {
+	ctx := context.Background()
+	rec := &Recorder{}
+	n := 0
+
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		n++
+		statusCode := http.StatusOK
+		if n < 2 {
+			statusCode = http.StatusBadGateway
+		}
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+			Header:     make(http.Header),
+		}
+	})
+
+	backoff := NewConfig(100*time.Microsecond, 10*time.Second, 2, 3).WithTracer(rec)
+	req, _ := http.NewRequest(http.MethodGet, "localhost:8080", bytes.NewBuffer(nil))
+
+	if _, err := DoWithRetry(ctx, backoff, client, req, http.StatusBadGateway); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	spans := rec.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("spans: got %d, want %d", len(spans), 3)
+	}
+	if got := spans[1].Attributes[AttrHTTPStatus]; got != http.StatusBadGateway {
+		t.Errorf("status: got %v, want %d", got, http.StatusBadGateway)
+	}
+	if got := spans[1].Attributes[AttrErrorClass]; got != ErrorClassServerError {
+		t.Errorf("error class: got %v, want %s", got, ErrorClassServerError)
+	}
+	if got := spans[2].Attributes[AttrHTTPStatus]; got != http.StatusOK {
+		t.Errorf("status: got %v, want %d", got, http.StatusOK)
+	}
+}
+
+func TestRetryContext_NestedSpan(t *testing.T) {
+	rec := &Recorder{}
+	err := RetryContext(context.Background(), DefaultBackoff().WithTracer(rec), func(ctx context.Context) (bool, error) {
+		_, span := rec.Start(ctx, "callee")
+		span.End()
+		return true, nil
+	})
+	if err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	spans := rec.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("spans: got %d, want %d", len(spans), 3)
+	}
+	if s := spans[2]; s.Name != "callee" || s.Parent == nil || s.Parent.Name != AttemptSpanName {
+		t.Errorf("callee span is not child of attempt span: %+v", s)
+	}
+}
+
+func TestRetryTrace_LastAttempt(t *testing.T) {
+	rec := &Recorder{}
+	sleeps := 0
+	err := retry(context.Background(), NewConfig(time.Millisecond, time.Millisecond, 1, 3).WithTracer(rec),
+		func() (bool, error) { return false, errors.New("retriable") },
+		func(context.Context, time.Duration) error {
+			sleeps++
+			return nil
+		})
+	if err == nil {
+		t.Error("got nil, want error")
+	}
+	if sleeps != 2 {
+		t.Errorf("sleeps: got %d, want %d", sleeps, 2)
+	}
+	spans := rec.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("spans: got %d, want %d", len(spans), 4)
+	}
+	if _, ok := spans[3].Attributes[AttrDelay]; ok {
+		t.Error("delay is recorded on last attempt")
+	}
+}
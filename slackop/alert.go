@@ -0,0 +1,333 @@
+package slackop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long alerts of the same key are suppressed when dedup_window is not configured
+const DefaultDedupWindow = 10 * time.Minute
+
+// ErrNoRoute is returned when none of the rules matches with the alert
+var ErrNoRoute = errors.New("slackop: no route for alert")
+
+// Severity is level of Alert
+type Severity int
+
+// Severities in ascending order
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+var severityNames = []string{"debug", "info", "warning", "error", "critical"}
+
+// severityColors are colors of attachment by Severity
+var severityColors = []string{"#aaaaaa", "#439fe0", "#daa038", "#d50200", "#8b0000"}
+
+// resolvedColor is color of attachment of resolved alert
+const resolvedColor = "#2eb886"
+
+// ParseSeverity gives Severity of name such as "error"
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes Severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes Severity from name such as "error"
+func (s *Severity) UnmarshalText(b []byte) error {
+	v, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+func (s Severity) color() string {
+	if s < 0 || int(s) >= len(severityColors) {
+		return ""
+	}
+	return severityColors[s]
+}
+
+// Alert is a notification of a condition which can be resolved later
+type Alert struct {
+	// Key identifies the condition. Alerts of the same key are deduplicated.
+	Key      string
+	Source   string
+	Severity Severity
+	Title    string
+	Text     string
+}
+
+// AlertRule routes alerts of MinSeverity or higher from Sources to Destination.
+// Empty Sources matches with any source.
+type AlertRule struct {
+	MinSeverity Severity `json:"min_severity"`
+	Sources     []string `json:"sources,omitempty"`
+	// Destination is channel or webhook URL interpreted by SenderFunc
+	Destination string `json:"destination"`
+}
+
+func (r *AlertRule) match(a Alert) bool {
+	if a.Severity < r.MinSeverity {
+		return false
+	}
+	if len(r.Sources) == 0 {
+		return true
+	}
+	for _, s := range r.Sources {
+		if s == a.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertConfig is configuration of AlertRouter. Rules are evaluated in order and the first match is used.
+//
+//	{
+//	  "dedup_window": "10m",
+//	  "rules": [
+//	    {"min_severity": "error", "destination": "#oncall"},
+//	    {"min_severity": "debug", "destination": "#feed"}
+//	  ]
+//	}
+type AlertConfig struct {
+	DedupWindow time.Duration
+	Rules       []AlertRule
+}
+
+// UnmarshalJSON decodes dedup_window given as duration string such as "10m"
+func (c *AlertConfig) UnmarshalJSON(b []byte) error {
+	var v struct {
+		DedupWindow string      `json:"dedup_window"`
+		Rules       []AlertRule `json:"rules"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	c.DedupWindow = 0
+	if v.DedupWindow != "" {
+		d, err := time.ParseDuration(v.DedupWindow)
+		if err != nil {
+			return fmt.Errorf("invalid dedup_window: %v", err)
+		}
+		c.DedupWindow = d
+	}
+	c.Rules = v.Rules
+	return nil
+}
+
+// LoadAlertConfig reads AlertConfig from JSON file
+func LoadAlertConfig(path string) (*AlertConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &AlertConfig{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// AlertRouter sends alerts to destinations chosen by rules.
+// Alert of the same key is sent once within the dedup window, and
+// "resolved" message is sent to the same destination when it's resolved.
+type AlertRouter struct {
+	rules  []AlertRule
+	window time.Duration
+	send   SenderFunc
+	now    func() time.Time
+
+	mu     sync.Mutex
+	active map[string]*alertState
+}
+
+// alertState is alert which is not resolved yet
+type alertState struct {
+	alert      Alert
+	dest       string
+	since      time.Time
+	notified   time.Time
+	suppressed int
+}
+
+// NewAlertRouter gives AlertRouter delivering by send such as ChannelSender or Queue.Enqueue
+func NewAlertRouter(cfg AlertConfig, send SenderFunc) (*AlertRouter, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("no alert rules")
+	}
+	for i, r := range cfg.Rules {
+		if r.Destination == "" {
+			return nil, fmt.Errorf("rules[%d]: destination is required", i)
+		}
+	}
+	window := cfg.DedupWindow
+	if window == 0 {
+		window = DefaultDedupWindow
+	}
+	return &AlertRouter{
+		rules:  cfg.Rules,
+		window: window,
+		send:   send,
+		now:    time.Now,
+		active: map[string]*alertState{},
+	}, nil
+}
+
+// Route gives destination of a by the first matching rule
+func (r *AlertRouter) Route(a Alert) (string, bool) {
+	for i := range r.rules {
+		if r.rules[i].match(a) {
+			return r.rules[i].Destination, true
+		}
+	}
+	return "", false
+}
+
+// Fire sends a unless alert of the same key was sent within the dedup window.
+// Alert escalated to higher severity or routed to another destination is sent within the window.
+// It reports whether a was sent. Alert without Key is always sent.
+func (r *AlertRouter) Fire(ctx context.Context, a Alert) (bool, error) {
+	dest, ok := r.Route(a)
+	if !ok {
+		return false, ErrNoRoute
+	}
+	if a.Key == "" {
+		return true, r.send(ctx, dest, alertMessage(a))
+	}
+
+	r.mu.Lock()
+	now := r.now()
+	prev := r.active[a.Key]
+	if prev != nil && now.Sub(prev.notified) < r.window && a.Severity <= prev.alert.Severity && dest == prev.dest {
+		prev.suppressed++
+		r.mu.Unlock()
+		return false, nil
+	}
+	st := &alertState{alert: a, dest: dest, since: now, notified: now}
+	if prev != nil {
+		st.since = prev.since
+		st.suppressed = prev.suppressed
+	}
+	r.active[a.Key] = st
+	r.mu.Unlock()
+
+	if err := r.send(ctx, dest, alertMessage(a)); err != nil {
+		r.restore(a.Key, st, prev)
+		return false, err
+	}
+	return true, nil
+}
+
+// Resolve sends "resolved" message of the alert of key to where it was sent.
+// It reports false when the alert is not firing.
+func (r *AlertRouter) Resolve(ctx context.Context, key string) (bool, error) {
+	r.mu.Lock()
+	st := r.active[key]
+	if st == nil {
+		r.mu.Unlock()
+		return false, nil
+	}
+	delete(r.active, key)
+	duration := r.now().Sub(st.since)
+	r.mu.Unlock()
+
+	if err := r.send(ctx, st.dest, resolvedMessage(st, duration)); err != nil {
+		r.restore(key, nil, st)
+		return false, err
+	}
+	return true, nil
+}
+
+// restore puts prev back as state of key when it's still cur
+func (r *AlertRouter) restore(key string, cur, prev *alertState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active[key] != cur {
+		return
+	}
+	if prev == nil {
+		delete(r.active, key)
+		return
+	}
+	r.active[key] = prev
+}
+
+// alertMessage gives message of firing alert
+func alertMessage(a Alert) *Message {
+	title := fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity.String()), a.Title)
+	att := Attachment{
+		Color:    a.Severity.color(),
+		Fallback: title,
+		Title:    title,
+		Text:     a.Text,
+		Footer:   a.Key,
+	}
+	if a.Source != "" {
+		att.Fields = append(att.Fields, AttachmentField{Title: "Source", Value: a.Source, Short: true})
+	}
+	att.Fields = append(att.Fields, AttachmentField{Title: "Severity", Value: a.Severity.String(), Short: true})
+	return &Message{Attachments: []Attachment{att}}
+}
+
+// resolvedMessage gives follow-up message of resolved alert
+func resolvedMessage(st *alertState, d time.Duration) *Message {
+	title := "[RESOLVED] " + st.alert.Title
+	text := fmt.Sprintf("Resolved after %s", d.Round(time.Second))
+	if st.suppressed > 0 {
+		text += fmt.Sprintf(" (%d duplicates suppressed)", st.suppressed)
+	}
+	att := Attachment{
+		Color:    resolvedColor,
+		Fallback: title,
+		Title:    title,
+		Text:     text,
+		Footer:   st.alert.Key,
+	}
+	if st.alert.Source != "" {
+		att.Fields = append(att.Fields, AttachmentField{Title: "Source", Value: st.alert.Source, Short: true})
+	}
+	return &Message{Attachments: []Attachment{att}}
+}
+
+// DestinationSender gives SenderFunc which posts to webhook when dest is URL such as "https://hooks.slack.com/...",
+// otherwise to the channel by c. It lets alert rules mix channels and webhooks.
+func DestinationSender(c *Client, opts ...Option) SenderFunc {
+	webhook := WebhookSender(opts...)
+	channel := ChannelSender(c)
+	return func(ctx context.Context, dest string, msg *Message) error {
+		if strings.HasPrefix(dest, "https://") || strings.HasPrefix(dest, "http://") {
+			return webhook(ctx, dest, msg)
+		}
+		return channel(ctx, dest, msg)
+	}
+}
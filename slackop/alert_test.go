@@ -0,0 +1,224 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivered records messages sent by SenderFunc
+type delivered struct {
+	mu    sync.Mutex
+	dests []string
+	msgs  []*Message
+	err   error
+}
+
+func (d *delivered) send(ctx context.Context, dest string, msg *Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	d.dests = append(d.dests, dest)
+	d.msgs = append(d.msgs, msg)
+	return nil
+}
+
+func newTestAlertRouter(t *testing.T, d *delivered) (*AlertRouter, *time.Time) {
+	t.Helper()
+	cfg, err := LoadAlertConfig("testdata/alerts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewAlertRouter(*cfg, d.send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestLoadAlertConfig(t *testing.T) {
+	cfg, err := LoadAlertConfig("testdata/alerts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DedupWindow != 5*time.Minute {
+		t.Errorf("got window %v", cfg.DedupWindow)
+	}
+	if len(cfg.Rules) != 3 || cfg.Rules[0].MinSeverity != SeverityError || cfg.Rules[1].Sources[0] != "billing" {
+		t.Errorf("got rules %+v", cfg.Rules)
+	}
+}
+
+func TestAlertConfig_Invalid(t *testing.T) {
+	for _, in := range []string{
+		`{"rules": [{"min_severity": "fatal", "destination": "#feed"}]}`,
+		`{"dedup_window": "soon"}`,
+	} {
+		var cfg AlertConfig
+		if err := cfg.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("%s: got no error", in)
+		}
+	}
+	if _, err := NewAlertRouter(AlertConfig{Rules: []AlertRule{{}}}, nil); err == nil {
+		t.Error("got no error for rule without destination")
+	}
+}
+
+func TestAlertRouter_Route(t *testing.T) {
+	r, _ := newTestAlertRouter(t, &delivered{})
+	for _, tt := range []struct {
+		alert Alert
+		want  string
+	}{
+		{Alert{Severity: SeverityCritical, Source: "api"}, "#oncall"},
+		{Alert{Severity: SeverityError, Source: "billing"}, "#oncall"},
+		{Alert{Severity: SeverityWarning, Source: "billing"}, "https://hooks.slack.com/services/T000/B000/XXX"},
+		{Alert{Severity: SeverityWarning, Source: "api"}, "#feed"},
+		{Alert{Severity: SeverityInfo}, "#feed"},
+	} {
+		if got, _ := r.Route(tt.alert); got != tt.want {
+			t.Errorf("%v from %s: got %s, want %s", tt.alert.Severity, tt.alert.Source, got, tt.want)
+		}
+	}
+}
+
+func TestAlertRouter_NoRoute(t *testing.T) {
+	r, err := NewAlertRouter(AlertConfig{Rules: []AlertRule{{MinSeverity: SeverityError, Destination: "#oncall"}}}, (&delivered{}).send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Fire(context.Background(), Alert{Severity: SeverityInfo}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("got %v, want ErrNoRoute", err)
+	}
+}
+
+func TestAlertRouter_DedupAndResolve(t *testing.T) {
+	d := &delivered{}
+	r, now := newTestAlertRouter(t, d)
+	ctx := context.Background()
+	a := Alert{Key: "db-down", Source: "api", Severity: SeverityError, Title: "DB is down", Text: "connection refused"}
+
+	if sent, err := r.Fire(ctx, a); !sent || err != nil {
+		t.Fatalf("got %v %v, want sent", sent, err)
+	}
+	*now = now.Add(time.Minute)
+	if sent, err := r.Fire(ctx, a); sent || err != nil {
+		t.Fatalf("got %v %v, want suppressed", sent, err)
+	}
+	*now = now.Add(5 * time.Minute)
+	if sent, err := r.Fire(ctx, a); !sent || err != nil {
+		t.Fatalf("got %v %v, want sent after window", sent, err)
+	}
+	*now = now.Add(time.Minute)
+	if resolved, err := r.Resolve(ctx, "db-down"); !resolved || err != nil {
+		t.Fatalf("got %v %v, want resolved", resolved, err)
+	}
+	if resolved, err := r.Resolve(ctx, "db-down"); resolved || err != nil {
+		t.Fatalf("got %v %v, want not firing", resolved, err)
+	}
+
+	if got := strings.Join(d.dests, ","); got != "#oncall,#oncall,#oncall" {
+		t.Errorf("got destinations %s", got)
+	}
+	fired := d.msgs[0].Attachments[0]
+	if fired.Title != "[ERROR] DB is down" || fired.Color != "#d50200" || fired.Footer != "db-down" {
+		t.Errorf("got fired %+v", fired)
+	}
+	resolved := d.msgs[2].Attachments[0]
+	if resolved.Title != "[RESOLVED] DB is down" || resolved.Color != resolvedColor {
+		t.Errorf("got resolved %+v", resolved)
+	}
+	if want := "Resolved after 7m0s (1 duplicates suppressed)"; resolved.Text != want {
+		t.Errorf("got %q, want %q", resolved.Text, want)
+	}
+}
+
+func TestAlertRouter_Escalation(t *testing.T) {
+	d := &delivered{}
+	r, now := newTestAlertRouter(t, d)
+	ctx := context.Background()
+	a := Alert{Key: "latency", Source: "api", Severity: SeverityWarning, Title: "Latency is high"}
+
+	if sent, err := r.Fire(ctx, a); !sent || err != nil {
+		t.Fatalf("got %v %v, want sent", sent, err)
+	}
+	*now = now.Add(time.Minute)
+	a.Severity = SeverityCritical
+	if sent, err := r.Fire(ctx, a); !sent || err != nil {
+		t.Fatalf("got %v %v, want escalation sent", sent, err)
+	}
+	*now = now.Add(time.Minute)
+	if sent, err := r.Fire(ctx, a); sent || err != nil {
+		t.Fatalf("got %v %v, want suppressed", sent, err)
+	}
+	a.Severity = SeverityError
+	if sent, err := r.Fire(ctx, a); sent || err != nil {
+		t.Fatalf("got %v %v, want lower severity suppressed", sent, err)
+	}
+	r.Resolve(ctx, "latency")
+
+	if got := strings.Join(d.dests, ","); got != "#feed,#oncall,#oncall" {
+		t.Errorf("got destinations %s", got)
+	}
+}
+
+func TestAlertRouter_ResolvedToFiredDestination(t *testing.T) {
+	d := &delivered{}
+	r, _ := newTestAlertRouter(t, d)
+	ctx := context.Background()
+	r.Fire(ctx, Alert{Key: "quota", Source: "billing", Severity: SeverityWarning, Title: "Quota"})
+	r.Resolve(ctx, "quota")
+	if len(d.dests) != 2 || d.dests[0] != d.dests[1] || !strings.HasPrefix(d.dests[1], "https://") {
+		t.Errorf("got destinations %v, want the same webhook", d.dests)
+	}
+}
+
+func TestAlertRouter_SendError(t *testing.T) {
+	d := &delivered{err: errors.New("unavailable")}
+	r, _ := newTestAlertRouter(t, d)
+	ctx := context.Background()
+	a := Alert{Key: "db-down", Severity: SeverityError, Title: "DB is down"}
+	if _, err := r.Fire(ctx, a); err == nil {
+		t.Fatal("got no error")
+	}
+	d.err = nil
+	// Failed alert must not be suppressed
+	if sent, err := r.Fire(ctx, a); !sent || err != nil {
+		t.Fatalf("got %v %v, want sent", sent, err)
+	}
+	d.err = errors.New("unavailable")
+	if _, err := r.Resolve(ctx, "db-down"); err == nil {
+		t.Fatal("got no error")
+	}
+	d.err = nil
+	// Alert is still firing after failed resolve
+	if resolved, err := r.Resolve(ctx, "db-down"); !resolved || err != nil {
+		t.Fatalf("got %v %v, want resolved", resolved, err)
+	}
+}
+
+func TestDestinationSender(t *testing.T) {
+	s, c := newTestClient(t)
+	send := DestinationSender(c)
+	ctx := context.Background()
+	if err := send(ctx, "#oncall", &Message{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(ctx, s.WebhookURL(), &Message{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, r := range s.Requests() {
+		methods = append(methods, r.Method)
+	}
+	if strings.Join(methods, ",") != "chat.postMessage,webhook" {
+		t.Errorf("got %v", methods)
+	}
+}
@@ -0,0 +1,180 @@
+// Package boltoutbox provides slackop.OutboxStore backed by embedded key-value store bbolt.
+package boltoutbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/fckey/go-sandbox/slackop"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the database
+var (
+	// bucketPending has entries keyed by sequence to keep added order
+	bucketPending = []byte("pending")
+	// bucketIndex maps ID to key in bucketPending
+	bucketIndex = []byte("index")
+	// bucketDelivered maps ID to time of delivery
+	bucketDelivered = []byte("delivered")
+)
+
+// Store is slackop.OutboxStore of bbolt database
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration
+	now       func() time.Time
+
+	// nextPrune is guarded by the writable transaction of db
+	nextPrune time.Time
+}
+
+// Option configures Store
+type Option func(*Store)
+
+// WithDeliveredRetention sets how long IDs of delivered messages are kept to drop duplicates
+func WithDeliveredRetention(d time.Duration) Option {
+	return func(s *Store) {
+		s.retention = d
+	}
+}
+
+// Open opens or creates database at path.
+// IDs delivered before the retention are removed on open and by MarkDelivered once per retention.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{retention: slackop.DefaultDeliveredRetention, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// init creates buckets and removes expired delivered IDs
+func (s *Store) init() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPending, bucketIndex, bucketDelivered} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return s.prune(tx, s.now())
+	})
+}
+
+// prune removes delivered IDs out of the retention
+func (s *Store) prune(tx *bolt.Tx, now time.Time) error {
+	s.nextPrune = now.Add(s.retention)
+	b := tx.Bucket(bucketDelivered)
+	// Deleting with cursor while iterating skips the next key
+	var expired [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if s.expired(v, now) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired reports whether ID delivered at encoded time v is out of the retention
+func (s *Store) expired(v []byte, now time.Time) bool {
+	var t time.Time
+	return t.UnmarshalBinary(v) != nil || t.Before(now.Add(-s.retention))
+}
+
+// Add implements slackop.OutboxStore
+func (s *Store) Add(e slackop.OutboxEntry) (bool, error) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id := []byte(e.ID)
+		index := tx.Bucket(bucketIndex)
+		if index.Get(id) != nil {
+			return nil
+		}
+		if v := tx.Bucket(bucketDelivered).Get(id); v != nil && !s.expired(v, s.now()) {
+			return nil
+		}
+		pending := tx.Bucket(bucketPending)
+		seq, err := pending.NextSequence()
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := pending.Put(key, v); err != nil {
+			return err
+		}
+		added = true
+		return index.Put(id, key)
+	})
+	return added, err
+}
+
+// MarkDelivered implements slackop.OutboxStore
+func (s *Store) MarkDelivered(id string) error {
+	now := s.now()
+	t, err := now.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		index := tx.Bucket(bucketIndex)
+		if key := index.Get([]byte(id)); key != nil {
+			if err := tx.Bucket(bucketPending).Delete(key); err != nil {
+				return err
+			}
+			if err := index.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(bucketDelivered).Put([]byte(id), t); err != nil {
+			return err
+		}
+		if now.After(s.nextPrune) {
+			return s.prune(tx, now)
+		}
+		return nil
+	})
+}
+
+// Pending implements slackop.OutboxStore
+func (s *Store) Pending() ([]slackop.OutboxEntry, error) {
+	var entries []slackop.OutboxEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPending).ForEach(func(k, v []byte) error {
+			var e slackop.OutboxEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Close implements slackop.OutboxStore
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,130 @@
+package boltoutbox
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/slackop"
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"2", "1", "3"} {
+		if added, err := s.Add(slackop.OutboxEntry{ID: id, Dest: "#feed", Msg: &slackop.Message{Text: "tweet" + id}}); !added || err != nil {
+			t.Fatalf("got %v %v, want added", added, err)
+		}
+	}
+	if added, _ := s.Add(slackop.OutboxEntry{ID: "1"}); added {
+		t.Error("pending ID was added again")
+	}
+	if err := s.MarkDelivered("1"); err != nil {
+		t.Fatal(err)
+	}
+	if added, _ := s.Add(slackop.OutboxEntry{ID: "1"}); added {
+		t.Error("delivered ID was added again")
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	pending, err := s.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "2" || pending[1].ID != "3" || pending[1].Msg.Text != "tweet3" {
+		t.Errorf("got %+v, want 2 and 3 in added order", pending)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(slackop.OutboxEntry{ID: "1"})
+	s.MarkDelivered("1")
+	s.Close()
+
+	s, err = Open(path, WithDeliveredRetention(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = Open(path, WithDeliveredRetention(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if added, _ := s.Add(slackop.OutboxEntry{ID: "1"}); !added {
+		t.Error("ID delivered before retention was not added")
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	s, err := Open(filepath.Join(t.TempDir(), "outbox.db"), WithDeliveredRetention(time.Hour), func(s *Store) { s.now = func() time.Time { return now } })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, id := range []string{"1", "2", "3"} {
+		s.Add(slackop.OutboxEntry{ID: id})
+		if err := s.MarkDelivered(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(time.Hour + time.Second)
+	if added, _ := s.Add(slackop.OutboxEntry{ID: "1"}); !added {
+		t.Error("ID delivered before retention was not added")
+	}
+	if err := s.MarkDelivered("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketDelivered).Stats().KeyN
+		return nil
+	})
+	if n != 1 {
+		t.Errorf("got %d delivered IDs, want 1", n)
+	}
+}
+
+func TestStore_Outbox(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	s, err := Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	o, err := slackop.NewOutbox(ctx, s, slackop.WebhookSender(), slackop.WithRateInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Enqueue(ctx, "1", srv.WebhookURL(), &slackop.Message{Text: "hello"})
+	o.Enqueue(ctx, "1", srv.WebhookURL(), &slackop.Message{Text: "hello"})
+	o.Flush(ctx)
+	if pending, _ := s.Pending(); len(pending) != 0 {
+		t.Errorf("got pending %v", pending)
+	}
+	if err := o.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.RequestsOf(slacktest.MethodWebhook)); n != 1 {
+		t.Errorf("got %d deliveries, want 1", n)
+	}
+}
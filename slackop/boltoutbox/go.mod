@@ -0,0 +1,24 @@
+module github.com/fckey/go-sandbox/slackop/boltoutbox
+
+go 1.23.0
+
+replace (
+	github.com/fckey/go-sandbox/retry => ../../retry
+	github.com/fckey/go-sandbox/slackop => ../
+)
+
+require (
+	github.com/fckey/go-sandbox/slackop v0.0.0-00010101000000-000000000000
+	go.etcd.io/bbolt v1.4.3
+)
+
+require (
+	github.com/fckey/go-sandbox/retry v0.0.0-00010101000000-000000000000 // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/googleapis/gax-go/v2 v2.0.5 // indirect
+	golang.org/x/net v0.0.0-20190503192946-f4e77d36d62c // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.3.2 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+	google.golang.org/grpc v1.27.0 // indirect
+)
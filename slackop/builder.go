@@ -0,0 +1,108 @@
+package slackop
+
+// MessageBuilder builds Message in fluent manner.
+//
+//	msg, err := slackop.NewMessageBuilder("3 new tweets").
+//		Header("Cloud Google").
+//		Section("*3* new tweets").
+//		Divider().
+//		Actions(slackop.NewButton("open", "Open").WithURL(url)).
+//		Build()
+type MessageBuilder struct {
+	msg Message
+}
+
+// NewMessageBuilder starts Message having text.
+// text is shown in notification when blocks are added.
+func NewMessageBuilder(text string) *MessageBuilder {
+	return &MessageBuilder{msg: Message{Text: text}}
+}
+
+// Username overrides name of the poster
+func (b *MessageBuilder) Username(name string) *MessageBuilder {
+	b.msg.Username = name
+	return b
+}
+
+// IconEmoji overrides icon of the poster such as ":bird:"
+func (b *MessageBuilder) IconEmoji(emoji string) *MessageBuilder {
+	b.msg.IconEmoji = emoji
+	return b
+}
+
+// Channel sets destination channel
+func (b *MessageBuilder) Channel(channel string) *MessageBuilder {
+	b.msg.Channel = channel
+	return b
+}
+
+// ThreadTS makes the message a reply in thread of ts
+func (b *MessageBuilder) ThreadTS(ts string) *MessageBuilder {
+	b.msg.ThreadTS = ts
+	return b
+}
+
+// Block appends any block
+func (b *MessageBuilder) Block(blocks ...Block) *MessageBuilder {
+	b.msg.Blocks = append(b.msg.Blocks, blocks...)
+	return b
+}
+
+// Header appends header block
+func (b *MessageBuilder) Header(text string) *MessageBuilder {
+	return b.Block(NewHeaderBlock(text))
+}
+
+// Section appends section block having text in mrkdwn
+func (b *MessageBuilder) Section(text string) *MessageBuilder {
+	return b.Block(NewSectionBlock(Markdown(text)))
+}
+
+// Fields appends section block having fields in mrkdwn
+func (b *MessageBuilder) Fields(fields ...string) *MessageBuilder {
+	s := NewSectionBlock(nil)
+	for _, f := range fields {
+		s.Fields = append(s.Fields, Markdown(f))
+	}
+	return b.Block(s)
+}
+
+// Divider appends divider block
+func (b *MessageBuilder) Divider() *MessageBuilder {
+	return b.Block(NewDividerBlock())
+}
+
+// Context appends context block having texts in mrkdwn
+func (b *MessageBuilder) Context(texts ...string) *MessageBuilder {
+	c := NewContextBlock()
+	for _, t := range texts {
+		c.Elements = append(c.Elements, Markdown(t))
+	}
+	return b.Block(c)
+}
+
+// Actions appends actions block having elements such as buttons
+func (b *MessageBuilder) Actions(elements ...Element) *MessageBuilder {
+	return b.Block(NewActionsBlock(elements...))
+}
+
+// Image appends image block
+func (b *MessageBuilder) Image(imageURL, altText string) *MessageBuilder {
+	return b.Block(NewImageBlock(imageURL, altText))
+}
+
+// Attachment appends attachment
+func (b *MessageBuilder) Attachment(attachments ...Attachment) *MessageBuilder {
+	b.msg.Attachments = append(b.msg.Attachments, attachments...)
+	return b
+}
+
+// Build validates and gives the built Message.
+// Error is *ValidationError when the message exceeds limits of Slack.
+func (b *MessageBuilder) Build() (*Message, error) {
+	msg := b.msg
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,323 @@
+package slackop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Response types of slash command and interaction responses
+const (
+	ResponseTypeInChannel = "in_channel"
+	ResponseTypeEphemeral = "ephemeral"
+)
+
+// DefaultDelayedResponseTimeout is timeout of handler running after acknowledgement.
+// Slack accepts response_url for 30 minutes.
+const DefaultDelayedResponseTimeout = 5 * time.Minute
+
+// SlashCommand is request of slash command such as "/pollkey Cloud Run"
+type SlashCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	ChannelID   string
+	ChannelName string
+	TeamID      string
+	TeamDomain  string
+	ResponseURL string
+	TriggerID   string
+}
+
+// InteractionCallback is payload of interactive components such as button click
+type InteractionCallback struct {
+	Type        string        `json:"type"`
+	TriggerID   string        `json:"trigger_id"`
+	ResponseURL string        `json:"response_url"`
+	User        IDName        `json:"user"`
+	Channel     IDName        `json:"channel"`
+	Team        IDName        `json:"team"`
+	Container   Container     `json:"container"`
+	Actions     []BlockAction `json:"actions"`
+}
+
+// IDName is user, channel or team in InteractionCallback
+type IDName struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Username is given for user
+	Username string `json:"username,omitempty"`
+}
+
+// Container is the message holding the component
+type Container struct {
+	Type      string `json:"type"`
+	MessageTS string `json:"message_ts"`
+	ChannelID string `json:"channel_id"`
+}
+
+// BlockAction is action made by user on an element
+type BlockAction struct {
+	Type     string `json:"type"`
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+	ActionTS string `json:"action_ts"`
+}
+
+// Response is reply to slash command or interaction.
+// It's sent as HTTP response or posted to response_url.
+type Response struct {
+	ResponseType    string       `json:"response_type,omitempty"`
+	Text            string       `json:"text,omitempty"`
+	Blocks          []Block      `json:"blocks,omitempty"`
+	Attachments     []Attachment `json:"attachments,omitempty"`
+	ThreadTS        string       `json:"thread_ts,omitempty"`
+	ReplaceOriginal bool         `json:"replace_original,omitempty"`
+	DeleteOriginal  bool         `json:"delete_original,omitempty"`
+}
+
+// EphemeralResponse gives Response visible only to the user
+func EphemeralResponse(text string) *Response {
+	return &Response{ResponseType: ResponseTypeEphemeral, Text: text}
+}
+
+// InChannelResponse gives Response visible to the channel
+func InChannelResponse(text string) *Response {
+	return &Response{ResponseType: ResponseTypeInChannel, Text: text}
+}
+
+// CommandHandler handles slash command. Returned Response can be nil to just acknowledge.
+type CommandHandler func(ctx context.Context, cmd SlashCommand) (*Response, error)
+
+// ActionHandler handles action of interactive component. Returned Response can be nil to just acknowledge.
+type ActionHandler func(ctx context.Context, cb InteractionCallback, action BlockAction) (*Response, error)
+
+// Handler is http.Handler receiving slash commands and interactive components.
+// Requests are verified by signing secret and dispatched by command name or action_id.
+type Handler struct {
+	verifier   *Verifier
+	httpClient *http.Client
+	timeout    time.Duration
+	onError    func(err error)
+
+	mu       sync.RWMutex
+	commands map[string]CommandHandler
+	actions  map[string]ActionHandler
+}
+
+// HandlerOption configures Handler
+type HandlerOption func(*Handler)
+
+// WithReplayWindow sets allowed age of requests
+func WithReplayWindow(d time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.verifier.window = d
+	}
+}
+
+// WithResponseHTTPClient sets http.Client posting to response_url
+func WithResponseHTTPClient(c *http.Client) HandlerOption {
+	return func(h *Handler) {
+		h.httpClient = c
+	}
+}
+
+// WithDelayedResponseTimeout sets timeout of handler running after acknowledgement
+func WithDelayedResponseTimeout(d time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.timeout = d
+	}
+}
+
+// WithHandlerErrorHandler sets f called with errors of handlers and delayed responses
+func WithHandlerErrorHandler(f func(err error)) HandlerOption {
+	return func(h *Handler) {
+		h.onError = f
+	}
+}
+
+// NewHandler gives Handler verifying requests by signingSecret.
+// ErrEmptySigningSecret is returned when signingSecret is empty since anyone could sign requests.
+func NewHandler(signingSecret string, opts ...HandlerOption) (*Handler, error) {
+	if signingSecret == "" {
+		return nil, ErrEmptySigningSecret
+	}
+	h := &Handler{
+		verifier:   NewVerifier(signingSecret),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultDelayedResponseTimeout,
+		onError:    func(error) {},
+		commands:   map[string]CommandHandler{},
+		actions:    map[string]ActionHandler{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h, nil
+}
+
+// HandleCommand registers f for command such as "/pollkey".
+// Response of f is given as HTTP response, so f should finish within 3 seconds.
+func (h *Handler) HandleCommand(command string, f CommandHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands[command] = f
+}
+
+// HandleCommandAsync registers f for command which takes longer than 3 seconds.
+// The command is acknowledged by ack immediately, and Response of f is posted to response_url.
+func (h *Handler) HandleCommandAsync(command string, ack *Response, f CommandHandler) {
+	h.HandleCommand(command, func(ctx context.Context, cmd SlashCommand) (*Response, error) {
+		go h.respondLater(cmd.ResponseURL, func(ctx context.Context) (*Response, error) {
+			return f(ctx, cmd)
+		})
+		return ack, nil
+	})
+}
+
+// HandleAction registers f for action_id of interactive components
+func (h *Handler) HandleAction(actionID string, f ActionHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.actions[actionID] = f
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := h.verifier.VerifyRequest(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var resp *Response
+	if payload := form.Get("payload"); payload != "" {
+		resp, err = h.serveInteraction(r.Context(), payload)
+	} else {
+		resp, err = h.serveCommand(r.Context(), form)
+	}
+	if err != nil {
+		h.onError(err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, resp)
+}
+
+func (h *Handler) serveCommand(ctx context.Context, form url.Values) (*Response, error) {
+	cmd := SlashCommand{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		ChannelName: form.Get("channel_name"),
+		TeamID:      form.Get("team_id"),
+		TeamDomain:  form.Get("team_domain"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+	}
+	h.mu.RLock()
+	f, ok := h.commands[cmd.Command]
+	h.mu.RUnlock()
+	if !ok {
+		return EphemeralResponse(fmt.Sprintf("Unknown command %s", cmd.Command)), nil
+	}
+	return f(ctx, cmd)
+}
+
+func (h *Handler) serveInteraction(ctx context.Context, payload string) (*Response, error) {
+	var cb InteractionCallback
+	if err := json.Unmarshal([]byte(payload), &cb); err != nil {
+		return nil, fmt.Errorf("failed to decode interaction payload: %v", err)
+	}
+	for _, a := range cb.Actions {
+		h.mu.RLock()
+		f, ok := h.actions[a.ActionID]
+		h.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		resp, err := f(ctx, cb, a)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil && cb.ResponseURL != "" {
+			// Interaction doesn't accept message in HTTP response
+			go h.respondLater(cb.ResponseURL, func(context.Context) (*Response, error) {
+				return resp, nil
+			})
+		}
+	}
+	return nil, nil
+}
+
+// respondLater runs f and posts its Response to responseURL
+func (h *Handler) respondLater(responseURL string, f func(ctx context.Context) (*Response, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	resp, err := f(ctx)
+	if err != nil {
+		h.onError(err)
+		return
+	}
+	if resp == nil {
+		return
+	}
+	if err := h.Respond(ctx, responseURL, resp); err != nil {
+		h.onError(err)
+	}
+}
+
+// Respond posts resp to response_url of a command or interaction
+func (h *Handler) Respond(ctx context.Context, responseURL string, resp *Response) error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return newAPIError("response_url", res, "")
+	}
+	return nil
+}
+
+// writeResponse writes resp as JSON or empty acknowledgement when it's nil
+func writeResponse(w http.ResponseWriter, resp *Response) {
+	if resp == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,153 @@
+package slackop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// signedRequest gives request signed by testSigningSecret at ts
+func signedRequest(t *testing.T, path string, body string, ts time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	sec := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(HeaderTimestamp, sec)
+	r.Header.Set(HeaderSignature, Sign([]byte(testSigningSecret), sec, []byte(body)))
+	return r
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	// Example in https://api.slack.com/authentication/verifying-requests-from-slack
+	body := "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	header := http.Header{}
+	header.Set(HeaderTimestamp, "1531420618")
+	header.Set(HeaderSignature, "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503")
+
+	v := NewVerifier(testSigningSecret)
+	v.now = func() time.Time { return time.Unix(1531420618, 0).Add(time.Minute) }
+	if err := v.Verify(header, []byte(body)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := v.Verify(header, []byte(body+"&x=1")); err != ErrInvalidSignature {
+		t.Errorf("got %v, want %v", err, ErrInvalidSignature)
+	}
+	v.now = func() time.Time { return time.Unix(1531420618, 0).Add(10 * time.Minute) }
+	if err := v.Verify(header, []byte(body)); err != ErrExpiredTimestamp {
+		t.Errorf("got %v, want %v", err, ErrExpiredTimestamp)
+	}
+	if err := v.Verify(http.Header{}, []byte(body)); err != ErrMissingSignature {
+		t.Errorf("got %v, want %v", err, ErrMissingSignature)
+	}
+}
+
+func TestNewHandler_EmptySecret(t *testing.T) {
+	if _, err := NewHandler(""); err != ErrEmptySigningSecret {
+		t.Errorf("got %v, want %v", err, ErrEmptySigningSecret)
+	}
+}
+
+func TestHandler_Command(t *testing.T) {
+	h, err := NewHandler(testSigningSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got SlashCommand
+	h.HandleCommand("/pollkey", func(ctx context.Context, cmd SlashCommand) (*Response, error) {
+		got = cmd
+		return InChannelResponse("Polling " + cmd.Text), nil
+	})
+	h.HandleCommand("/fail", func(ctx context.Context, cmd SlashCommand) (*Response, error) {
+		return nil, errors.New("failed")
+	})
+
+	form := url.Values{"command": {"/pollkey"}, "text": {"Cloud Run"}, "user_id": {"U1"}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "/slack/commands", form.Encode(), time.Now()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusOK)
+	}
+	if got.Text != "Cloud Run" || got.UserID != "U1" {
+		t.Errorf("unexpected command: %+v", got)
+	}
+	resp := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["response_type"] != ResponseTypeInChannel || resp["text"] != "Polling Cloud Run" {
+		t.Errorf("unexpected response: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "/slack/commands", url.Values{"command": {"/fail"}}.Encode(), time.Now()))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	w = httptest.NewRecorder()
+	r := signedRequest(t, "/slack/commands", form.Encode(), time.Now().Add(-time.Hour))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandler_CommandAsync(t *testing.T) {
+	s := newTestWebhook(t)
+
+	h, err := NewHandler(testSigningSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.HandleCommandAsync("/pollkey", EphemeralResponse("Polling..."), func(ctx context.Context, cmd SlashCommand) (*Response, error) {
+		return InChannelResponse("Polled " + cmd.Text), nil
+	})
+	form := url.Values{"command": {"/pollkey"}, "text": {"Cloud Run"}, "response_url": {s.ResponseURL()}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "/slack/commands", form.Encode(), time.Now()))
+	if !strings.Contains(w.Body.String(), "Polling...") {
+		t.Errorf("unexpected ack: %s", w.Body)
+	}
+	reqs := s.WaitRequests(slacktest.MethodResponseURL, 1, time.Second)
+	if len(reqs) != 1 {
+		t.Fatal("delayed response was not posted")
+	}
+	if got := reqs[0].Payload(); got["text"] != "Polled Cloud Run" {
+		t.Errorf("unexpected delayed response: %v", got)
+	}
+}
+
+func TestHandler_Action(t *testing.T) {
+	s := newTestWebhook(t)
+
+	h, err := NewHandler(testSigningSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.HandleAction("mute", func(ctx context.Context, cb InteractionCallback, a BlockAction) (*Response, error) {
+		return &Response{ReplaceOriginal: true, Text: cb.User.Username + " muted " + a.Value}, nil
+	})
+	payload := `{"type":"block_actions","user":{"id":"U1","username":"gopher"},"response_url":"` + s.ResponseURL() +
+		`","actions":[{"type":"button","action_id":"mute","value":"Cloud Google"}]}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "/slack/actions", url.Values{"payload": {payload}}.Encode(), time.Now()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusOK)
+	}
+	reqs := s.WaitRequests(slacktest.MethodResponseURL, 1, time.Second)
+	if len(reqs) != 1 {
+		t.Fatal("response was not posted")
+	}
+	if got := reqs[0].Payload(); got["text"] != "gopher muted Cloud Google" || got["replace_original"] != true {
+		t.Errorf("unexpected response: %v", got)
+	}
+}
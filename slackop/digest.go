@@ -0,0 +1,271 @@
+package slackop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Default setting of Digest
+const (
+	DefaultDigestWindow = time.Minute
+	DefaultDigestTopN   = 5
+	// DefaultDigestTemplate renders such as "37 new notifications for 'Cloud Google'" followed by top items
+	DefaultDigestTemplate = `{{.Count}} new notifications for '{{.Key}}'
+{{range .Items}}• {{.Text}}
+{{end}}{{if .Rest}}and {{.Rest}} more{{end}}`
+)
+
+// ErrDigestClosed is returned when a notification is added after Close
+var ErrDigestClosed = errors.New("slackop: digest is closed")
+
+// Notification is an item summarized by Digest.
+// Items having higher Score are listed first in the summary.
+type Notification struct {
+	Key   string
+	Text  string
+	Score float64
+	Time  time.Time
+}
+
+// DigestSummary is given to the template of Digest
+type DigestSummary struct {
+	Key   string
+	Count int
+	// Items are top N notifications ordered by Score
+	Items []Notification
+	// Rest is number of notifications not listed in Items
+	Rest int
+	From time.Time
+	To   time.Time
+}
+
+// DigestRenderer makes Message from summary
+type DigestRenderer func(s DigestSummary) (*Message, error)
+
+// Digest batches notifications having the same key into one message.
+// A group is sent when its window passes since the first notification or
+// when it reaches the max count.
+type Digest struct {
+	send     func(ctx context.Context, msg *Message) error
+	window   time.Duration
+	maxCount int
+	topN     int
+	tmpl     string
+	render   DigestRenderer
+	onError  func(key string, err error)
+	// afterFunc starts the window of a group. It is replaced in tests.
+	afterFunc func(d time.Duration, f func()) digestTimer
+
+	mu     sync.Mutex
+	groups map[string]*digestGroup
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type digestGroup struct {
+	items []Notification
+	timer digestTimer
+}
+
+// digestTimer is the window of a group such as *time.Timer
+type digestTimer interface {
+	Stop() bool
+}
+
+// DigestOption configures Digest
+type DigestOption func(*Digest)
+
+// WithDigestWindow sets how long notifications are collected before sending
+func WithDigestWindow(d time.Duration) DigestOption {
+	return func(dg *Digest) {
+		dg.window = d
+	}
+}
+
+// WithDigestMaxCount sends the group as soon as it has n notifications. Zero disables it.
+func WithDigestMaxCount(n int) DigestOption {
+	return func(dg *Digest) {
+		dg.maxCount = n
+	}
+}
+
+// WithDigestTopN sets number of notifications listed in the summary. Negative n is taken as zero.
+func WithDigestTopN(n int) DigestOption {
+	return func(dg *Digest) {
+		if n < 0 {
+			n = 0
+		}
+		dg.topN = n
+	}
+}
+
+// WithDigestTemplate sets text/template rendering DigestSummary to text of the message
+func WithDigestTemplate(text string) DigestOption {
+	return func(dg *Digest) {
+		dg.tmpl = text
+	}
+}
+
+// WithDigestRenderer sets r making the message such as Block Kit message. It overrides the template.
+func WithDigestRenderer(r DigestRenderer) DigestOption {
+	return func(dg *Digest) {
+		dg.render = r
+	}
+}
+
+// WithDigestErrorHandler sets f called when sending a summary of window failed
+func WithDigestErrorHandler(f func(key string, err error)) DigestOption {
+	return func(dg *Digest) {
+		dg.onError = f
+	}
+}
+
+// NewDigest gives Digest sending summaries by send such as Manager.NotifyContext.
+// Error is returned when the template can't be parsed.
+func NewDigest(send func(ctx context.Context, msg *Message) error, opts ...DigestOption) (*Digest, error) {
+	dg := &Digest{
+		send:    send,
+		window:  DefaultDigestWindow,
+		topN:    DefaultDigestTopN,
+		tmpl:    DefaultDigestTemplate,
+		onError: func(string, error) {},
+		afterFunc: func(d time.Duration, f func()) digestTimer {
+			return time.AfterFunc(d, f)
+		},
+		groups: map[string]*digestGroup{},
+	}
+	for _, opt := range opts {
+		opt(dg)
+	}
+	if dg.render == nil {
+		t, err := template.New("digest").Parse(dg.tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse digest template: %v", err)
+		}
+		dg.render = templateRenderer(t)
+	}
+	return dg, nil
+}
+
+// templateRenderer gives DigestRenderer making plain text message by t
+func templateRenderer(t *template.Template) DigestRenderer {
+	return func(s DigestSummary) (*Message, error) {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, s); err != nil {
+			return nil, fmt.Errorf("failed to render digest: %v", err)
+		}
+		return &Message{Text: buf.String()}, nil
+	}
+}
+
+// Add puts n into the group of n.Key.
+// When the group reaches the max count, the summary is sent before returning.
+// ErrDigestClosed is returned after Close.
+func (dg *Digest) Add(ctx context.Context, n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	dg.mu.Lock()
+	if dg.closed {
+		dg.mu.Unlock()
+		return ErrDigestClosed
+	}
+	g, ok := dg.groups[n.Key]
+	if !ok {
+		g = &digestGroup{}
+		dg.groups[n.Key] = g
+		key := n.Key
+		dg.wg.Add(1)
+		g.timer = dg.afterFunc(dg.window, func() {
+			if err := dg.flushGroup(context.Background(), key, g); err != nil {
+				dg.onError(key, err)
+			}
+		})
+	}
+	g.items = append(g.items, n)
+	full := dg.maxCount > 0 && len(g.items) >= dg.maxCount
+	dg.mu.Unlock()
+
+	if full {
+		return dg.flushGroup(ctx, n.Key, g)
+	}
+	return nil
+}
+
+// Flush sends summaries of every group without waiting for the windows
+func (dg *Digest) Flush(ctx context.Context) error {
+	dg.mu.Lock()
+	groups := make(map[string]*digestGroup, len(dg.groups))
+	for k, g := range dg.groups {
+		groups[k] = g
+	}
+	dg.mu.Unlock()
+
+	var firstErr error
+	for k, g := range groups {
+		if err := dg.flushGroup(ctx, k, g); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close sends the pending summaries and waits for the summaries being sent by windows.
+// Notifications can't be added after Close.
+func (dg *Digest) Close(ctx context.Context) error {
+	dg.mu.Lock()
+	dg.closed = true
+	dg.mu.Unlock()
+	err := dg.Flush(ctx)
+	dg.wg.Wait()
+	return err
+}
+
+// flushGroup removes g from the groups and sends its summary.
+// It does nothing when g was already sent by the other trigger.
+func (dg *Digest) flushGroup(ctx context.Context, key string, g *digestGroup) error {
+	dg.mu.Lock()
+	if dg.groups[key] != g {
+		dg.mu.Unlock()
+		return nil
+	}
+	delete(dg.groups, key)
+	g.timer.Stop()
+	items := g.items
+	dg.mu.Unlock()
+	defer dg.wg.Done()
+
+	msg, err := dg.render(dg.summarize(key, items))
+	if err != nil {
+		return err
+	}
+	return dg.send(ctx, msg)
+}
+
+// summarize makes DigestSummary of items
+func (dg *Digest) summarize(key string, items []Notification) DigestSummary {
+	s := DigestSummary{Key: key, Count: len(items), From: items[0].Time, To: items[0].Time}
+	for _, n := range items {
+		if n.Time.Before(s.From) {
+			s.From = n.Time
+		}
+		if n.Time.After(s.To) {
+			s.To = n.Time
+		}
+	}
+	top := make([]Notification, len(items))
+	copy(top, items)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Score > top[j].Score })
+	if len(top) > dg.topN {
+		top = top[:dg.topN]
+	}
+	s.Items = top
+	s.Rest = len(items) - len(top)
+	return s
+}
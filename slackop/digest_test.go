@@ -0,0 +1,157 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sentMessages records messages sent by Digest
+type sentMessages struct {
+	mu   sync.Mutex
+	msgs []*Message
+}
+
+func (s *sentMessages) send(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func (s *sentMessages) get() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.msgs...)
+}
+
+// manualWindows records windows of Digest and fires them on demand
+type manualWindows struct {
+	mu sync.Mutex
+	fs []func()
+}
+
+func (w *manualWindows) afterFunc(d time.Duration, f func()) digestTimer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fs = append(w.fs, f)
+	return time.NewTimer(d)
+}
+
+// fire ends every window started so far
+func (w *manualWindows) fire() {
+	w.mu.Lock()
+	fs := w.fs
+	w.fs = nil
+	w.mu.Unlock()
+	for _, f := range fs {
+		f()
+	}
+}
+
+func TestDigest_Window(t *testing.T) {
+	sent := &sentMessages{}
+	dg, err := NewDigest(sent.send, WithDigestWindow(time.Hour), WithDigestTopN(2),
+		WithDigestTemplate(`{{.Count}} new tweets for '{{.Key}}'{{range .Items}}|{{.Text}}{{end}}{{if .Rest}}|+{{.Rest}}{{end}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	windows := &manualWindows{}
+	dg.afterFunc = windows.afterFunc
+	ctx := context.Background()
+	for i := 0; i < 37; i++ {
+		dg.Add(ctx, Notification{Key: "Cloud Google", Text: fmt.Sprintf("tweet%d", i), Score: float64(i % 10)})
+	}
+	dg.Add(ctx, Notification{Key: "Cloud Run", Text: "run"})
+	if got := len(sent.get()); got != 0 {
+		t.Fatalf("sent before window: %d", got)
+	}
+	windows.fire()
+
+	msgs := sent.get()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want %d", len(msgs), 2)
+	}
+	texts := map[string]bool{msgs[0].Text: true, msgs[1].Text: true}
+	for _, want := range []string{
+		"37 new tweets for 'Cloud Google'|tweet9|tweet19|+35",
+		"1 new tweets for 'Cloud Run'|run",
+	} {
+		if !texts[want] {
+			t.Errorf("%q is not sent: %v", want, texts)
+		}
+	}
+	if err := dg.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDigest_MaxCount(t *testing.T) {
+	sent := &sentMessages{}
+	dg, err := NewDigest(sent.send, WithDigestWindow(time.Hour), WithDigestMaxCount(3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		dg.Add(ctx, Notification{Key: "k", Text: fmt.Sprintf("n%d", i)})
+	}
+	if got := len(sent.get()); got != 1 {
+		t.Fatalf("got %d messages, want %d", got, 1)
+	}
+	if err := dg.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msgs := sent.get()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want %d", len(msgs), 2)
+	}
+	if !strings.HasPrefix(msgs[0].Text, "3 new notifications for 'k'") || !strings.HasPrefix(msgs[1].Text, "1 new notifications for 'k'") {
+		t.Errorf("unexpected messages: %q, %q", msgs[0].Text, msgs[1].Text)
+	}
+}
+
+func TestDigest_AddAfterClose(t *testing.T) {
+	sent := &sentMessages{}
+	dg, err := NewDigest(sent.send)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	if err := dg.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dg.Add(ctx, Notification{Key: "k", Text: "late"}); !errors.Is(err, ErrDigestClosed) {
+		t.Errorf("got %v, want %v", err, ErrDigestClosed)
+	}
+	if got := len(sent.get()); got != 0 {
+		t.Errorf("got %d messages, want %d", got, 0)
+	}
+}
+
+func TestDigest_NegativeTopN(t *testing.T) {
+	sent := &sentMessages{}
+	dg, err := NewDigest(sent.send, WithDigestTopN(-1), WithDigestMaxCount(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	dg.Add(ctx, Notification{Key: "k", Text: "a"})
+	if err := dg.Add(ctx, Notification{Key: "k", Text: "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msgs := sent.get()
+	if len(msgs) != 1 || !strings.HasSuffix(msgs[0].Text, "and 2 more") {
+		t.Errorf("unexpected messages: %v", msgs)
+	}
+}
+
+func TestNewDigest_InvalidTemplate(t *testing.T) {
+	if _, err := NewDigest(nil, WithDigestTemplate("{{.Count")); err == nil {
+		t.Error("got nil, want error")
+	}
+}
@@ -0,0 +1,98 @@
+package slackop
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error codes returned by Slack. APIError having the code matches them with errors.Is.
+// See https://api.slack.com/methods/chat.postMessage#errors and
+// https://api.slack.com/messaging/webhooks#handling_errors
+var (
+	ErrInvalidAuth       = errors.New("invalid_auth")
+	ErrNotAuthed         = errors.New("not_authed")
+	ErrTokenRevoked      = errors.New("token_revoked")
+	ErrAccountInactive   = errors.New("account_inactive")
+	ErrMissingScope      = errors.New("missing_scope")
+	ErrChannelNotFound   = errors.New("channel_not_found")
+	ErrNotInChannel      = errors.New("not_in_channel")
+	ErrIsArchived        = errors.New("is_archived")
+	ErrUserNotInChannel  = errors.New("user_not_in_channel")
+	ErrMessageNotFound   = errors.New("message_not_found")
+	ErrCantUpdateMessage = errors.New("cant_update_message")
+	ErrCantDeleteMessage = errors.New("cant_delete_message")
+	ErrMsgTooLong        = errors.New("msg_too_long")
+	ErrNoText            = errors.New("no_text")
+	ErrInvalidBlocks     = errors.New("invalid_blocks")
+	ErrInvalidPayload    = errors.New("invalid_payload")
+	ErrInvalidToken      = errors.New("invalid_token")
+	ErrNoService         = errors.New("no_service")
+	ErrRateLimited       = errors.New("ratelimited")
+)
+
+var errorCodes = map[string]error{}
+
+func init() {
+	for _, err := range []error{
+		ErrInvalidAuth, ErrNotAuthed, ErrTokenRevoked, ErrAccountInactive, ErrMissingScope,
+		ErrChannelNotFound, ErrNotInChannel, ErrIsArchived, ErrUserNotInChannel,
+		ErrMessageNotFound, ErrCantUpdateMessage, ErrCantDeleteMessage,
+		ErrMsgTooLong, ErrNoText, ErrInvalidBlocks, ErrInvalidPayload,
+		ErrInvalidToken, ErrNoService, ErrRateLimited,
+	} {
+		errorCodes[err.Error()] = err
+	}
+	// Incoming webhook reports archived channel by its own code
+	errorCodes["channel_is_archived"] = ErrIsArchived
+}
+
+// APIError is failure reported by Slack.
+// Code is error code in the response such as "channel_not_found" and can be empty
+// when Slack didn't give it. RetryAfter is given when Slack rate limits the call.
+type APIError struct {
+	Method     string
+	StatusCode int
+	Code       string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("slack %s failed with status %d", e.Method, e.StatusCode)
+	}
+	return fmt.Sprintf("slack %s failed with status %d: %s", e.Method, e.StatusCode, e.Code)
+}
+
+// Unwrap gives the error variable matching with Code such as ErrChannelNotFound
+func (e *APIError) Unwrap() error {
+	return errorCodes[e.Code]
+}
+
+// RetryDelay gives RetryAfter so that retry.Retry waits as Slack requested
+func (e *APIError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// newAPIError gives APIError for HTTP response and Slack error code
+func newAPIError(method string, resp *http.Response, code string) *APIError {
+	e := &APIError{Method: method, StatusCode: resp.StatusCode, Code: code}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if e.Code == "" {
+			e.Code = ErrRateLimited.Error()
+		}
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return e
+}
+
+// parseRetryAfter reads Retry-After header given in seconds
+func parseRetryAfter(v string) time.Duration {
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
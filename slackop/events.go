@@ -0,0 +1,282 @@
+package slackop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Envelope types of Events API
+const (
+	EnvelopeURLVerification = "url_verification"
+	EnvelopeEventCallback   = "event_callback"
+)
+
+// Event types handled by EventsHandler
+const (
+	EventTypeMessage       = "message"
+	EventTypeAppMention    = "app_mention"
+	EventTypeReactionAdded = "reaction_added"
+)
+
+// Default setting of EventsHandler
+const (
+	// DefaultEventDedupTTL covers retries of Slack which are made within an hour
+	DefaultEventDedupTTL = time.Hour
+	DefaultEventTimeout  = time.Minute
+)
+
+// EventCallback is envelope of event delivered by Events API
+type EventCallback struct {
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge,omitempty"`
+	TeamID    string          `json:"team_id"`
+	APIAppID  string          `json:"api_app_id"`
+	EventID   string          `json:"event_id"`
+	EventTime int64           `json:"event_time"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// MessageEvent is posted message
+type MessageEvent struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype,omitempty"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id,omitempty"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts,omitempty"`
+	EventTS     string `json:"event_ts"`
+}
+
+// AppMentionEvent is message mentioning the app
+type AppMentionEvent struct {
+	Type     string `json:"type"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	EventTS  string `json:"event_ts"`
+}
+
+// ReactionAddedEvent is reaction added to an item
+type ReactionAddedEvent struct {
+	Type     string       `json:"type"`
+	User     string       `json:"user"`
+	Reaction string       `json:"reaction"`
+	ItemUser string       `json:"item_user"`
+	Item     ReactionItem `json:"item"`
+	EventTS  string       `json:"event_ts"`
+}
+
+// ReactionItem is the item having the reaction
+type ReactionItem struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// EventHandler handles inner event of cb given as JSON
+type EventHandler func(ctx context.Context, cb *EventCallback, event json.RawMessage) error
+
+// EventsHandler is http.Handler of Events API endpoint.
+// It acknowledges events immediately and runs handlers asynchronously
+// so that the response is made within 3 seconds required by Slack.
+type EventsHandler struct {
+	verifier *Verifier
+	dedup    *ttlCache
+	timeout  time.Duration
+	onError  func(err error)
+
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+	wg       sync.WaitGroup
+}
+
+// EventsOption configures EventsHandler
+type EventsOption func(*EventsHandler)
+
+// WithEventDedupTTL sets how long event_id is remembered to drop redeliveries
+func WithEventDedupTTL(d time.Duration) EventsOption {
+	return func(h *EventsHandler) {
+		h.dedup.ttl = d
+	}
+}
+
+// WithEventTimeout sets timeout of each handler
+func WithEventTimeout(d time.Duration) EventsOption {
+	return func(h *EventsHandler) {
+		h.timeout = d
+	}
+}
+
+// WithEventErrorHandler sets f called with errors of handlers
+func WithEventErrorHandler(f func(err error)) EventsOption {
+	return func(h *EventsHandler) {
+		h.onError = f
+	}
+}
+
+// NewEventsHandler gives EventsHandler verifying requests by signingSecret.
+// ErrEmptySigningSecret is returned when signingSecret is empty since anyone could sign requests.
+func NewEventsHandler(signingSecret string, opts ...EventsOption) (*EventsHandler, error) {
+	if signingSecret == "" {
+		return nil, ErrEmptySigningSecret
+	}
+	h := &EventsHandler{
+		verifier: NewVerifier(signingSecret),
+		dedup:    newTTLCache(DefaultEventDedupTTL),
+		timeout:  DefaultEventTimeout,
+		onError:  func(error) {},
+		handlers: map[string][]EventHandler{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h, nil
+}
+
+// On registers f for inner event type such as "message"
+func (h *EventsHandler) On(eventType string, f EventHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[eventType] = append(h.handlers[eventType], f)
+}
+
+// OnMessage registers f for message events
+func (h *EventsHandler) OnMessage(f func(ctx context.Context, cb *EventCallback, ev *MessageEvent) error) {
+	h.On(EventTypeMessage, func(ctx context.Context, cb *EventCallback, raw json.RawMessage) error {
+		ev := &MessageEvent{}
+		if err := json.Unmarshal(raw, ev); err != nil {
+			return err
+		}
+		return f(ctx, cb, ev)
+	})
+}
+
+// OnAppMention registers f for app_mention events
+func (h *EventsHandler) OnAppMention(f func(ctx context.Context, cb *EventCallback, ev *AppMentionEvent) error) {
+	h.On(EventTypeAppMention, func(ctx context.Context, cb *EventCallback, raw json.RawMessage) error {
+		ev := &AppMentionEvent{}
+		if err := json.Unmarshal(raw, ev); err != nil {
+			return err
+		}
+		return f(ctx, cb, ev)
+	})
+}
+
+// OnReactionAdded registers f for reaction_added events
+func (h *EventsHandler) OnReactionAdded(f func(ctx context.Context, cb *EventCallback, ev *ReactionAddedEvent) error) {
+	h.On(EventTypeReactionAdded, func(ctx context.Context, cb *EventCallback, raw json.RawMessage) error {
+		ev := &ReactionAddedEvent{}
+		if err := json.Unmarshal(raw, ev); err != nil {
+			return err
+		}
+		return f(ctx, cb, ev)
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := h.verifier.VerifyRequest(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	cb := &EventCallback{}
+	if err := json.Unmarshal(body, cb); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	switch cb.Type {
+	case EnvelopeURLVerification:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(cb.Challenge))
+		return
+	case EnvelopeEventCallback:
+		if cb.EventID == "" || !h.dedup.add(cb.EventID) {
+			h.dispatch(cb)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// dispatch runs handlers of the inner event in background
+func (h *EventsHandler) dispatch(cb *EventCallback) {
+	t, err := typeOf(cb.Event)
+	if err != nil {
+		h.onError(fmt.Errorf("failed to decode event %s: %v", cb.EventID, err))
+		return
+	}
+	h.mu.RLock()
+	handlers := h.handlers[t]
+	h.mu.RUnlock()
+	for _, f := range handlers {
+		h.wg.Add(1)
+		go func(f EventHandler) {
+			defer h.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+			defer cancel()
+			if err := f(ctx, cb, cb.Event); err != nil {
+				h.onError(fmt.Errorf("failed to handle event %s: %v", cb.EventID, err))
+			}
+		}(f)
+	}
+}
+
+// Wait blocks until the running handlers finish such as on shutdown
+func (h *EventsHandler) Wait() {
+	h.wg.Wait()
+}
+
+// ttlCache remembers keys for ttl
+type ttlCache struct {
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	nextSweep time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, now: time.Now, keys: map[string]time.Time{}}
+}
+
+// add remembers key and reports whether it was already remembered
+func (c *ttlCache) add(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if exp, ok := c.keys[key]; ok && now.Before(exp) {
+		return true
+	}
+	if now.After(c.nextSweep) {
+		for k, exp := range c.keys {
+			if !now.Before(exp) {
+				delete(c.keys, k)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	c.keys[key] = now.Add(c.ttl)
+	return false
+}
+
+// has reports whether key is remembered
+func (c *ttlCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exp, ok := c.keys[key]
+	return ok && c.now().Before(exp)
+}
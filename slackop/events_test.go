@@ -0,0 +1,98 @@
+package slackop
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewEventsHandler_EmptySecret(t *testing.T) {
+	if _, err := NewEventsHandler(""); err != ErrEmptySigningSecret {
+		t.Errorf("got %v, want %v", err, ErrEmptySigningSecret)
+	}
+}
+
+func TestEventsHandler_URLVerification(t *testing.T) {
+	h, err := NewEventsHandler(testSigningSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body := `{"token":"Jhj5dZrVaK7ZwHHjRyZWjbDl","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P","type":"url_verification"}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "/slack/events", body, time.Now()))
+	if w.Code != http.StatusOK || w.Body.String() != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r := signedRequest(t, "/slack/events", body, time.Now())
+	r.Header.Set(HeaderSignature, "v0=0000")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestEventsHandler_Dispatch(t *testing.T) {
+	h, err := NewEventsHandler(testSigningSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var mu sync.Mutex
+	var mentions []*AppMentionEvent
+	var reactions []*ReactionAddedEvent
+	h.OnAppMention(func(ctx context.Context, cb *EventCallback, ev *AppMentionEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		mentions = append(mentions, ev)
+		return nil
+	})
+	h.OnReactionAdded(func(ctx context.Context, cb *EventCallback, ev *ReactionAddedEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		reactions = append(reactions, ev)
+		return nil
+	})
+
+	mention := `{"type":"event_callback","team_id":"T1","event_id":"Ev1","event_time":1,` +
+		`"event":{"type":"app_mention","user":"U1","text":"<@U0> pollkey Cloud Run","ts":"1.1","channel":"C1"}}`
+	reaction := `{"type":"event_callback","team_id":"T1","event_id":"Ev2","event_time":2,` +
+		`"event":{"type":"reaction_added","user":"U1","reaction":"eyes","item":{"type":"message","channel":"C1","ts":"1.1"}}}`
+	message := `{"type":"event_callback","team_id":"T1","event_id":"Ev3","event_time":3,` +
+		`"event":{"type":"message","user":"U1","text":"no handler","ts":"1.2","channel":"C1"}}`
+	// Ev1 is redelivered by Slack
+	for _, body := range []string{mention, reaction, mention, message} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, signedRequest(t, "/slack/events", body, time.Now()))
+		if w.Code != http.StatusOK {
+			t.Errorf("status: got %d, want %d", w.Code, http.StatusOK)
+		}
+	}
+	h.Wait()
+
+	if len(mentions) != 1 || mentions[0].Text != "<@U0> pollkey Cloud Run" {
+		t.Errorf("unexpected mentions: %+v", mentions)
+	}
+	if len(reactions) != 1 || reactions[0].Reaction != "eyes" || reactions[0].Item.TS != "1.1" {
+		t.Errorf("unexpected reactions: %+v", reactions)
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newTTLCache(time.Minute)
+	c.now = func() time.Time { return now }
+	if c.add("Ev1") {
+		t.Error("Ev1 is seen before added")
+	}
+	if !c.add("Ev1") {
+		t.Error("Ev1 is not seen")
+	}
+	now = now.Add(2 * time.Minute)
+	if c.add("Ev1") {
+		t.Error("Ev1 is seen after ttl")
+	}
+}
@@ -0,0 +1,220 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxUploadResponseSize is size of response body of upload URL read for error message
+const maxUploadResponseSize = 1024
+
+// ErrUnknownLength is returned by UploadFileExternal when length of the reader can't be known
+var ErrUnknownLength = errors.New("slackop: length of the file is required")
+
+// UploadParams is file uploaded to Slack.
+// Reader is streamed to Slack without being loaded into memory.
+type UploadParams struct {
+	Reader   io.Reader
+	Filename string
+	Title    string
+	// Channels are channel IDs where the file is shared. Empty uploads file without sharing.
+	Channels       []string
+	ThreadTS       string
+	InitialComment string
+	// Filetype is type of snippet such as "csv" or "go" for syntax highlighting
+	Filetype string
+	// Length is size of Reader required by UploadFileExternal.
+	// It's found from *os.File, *bytes.Reader, *strings.Reader and *bytes.Buffer when zero.
+	Length int64
+}
+
+// File is uploaded file
+type File struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Title      string `json:"title"`
+	Mimetype   string `json:"mimetype"`
+	Filetype   string `json:"filetype"`
+	Size       int64  `json:"size"`
+	URLPrivate string `json:"url_private"`
+	Permalink  string `json:"permalink"`
+}
+
+// UploadFile uploads file by files.upload as multipart body streamed from p.Reader
+func (c *Client) UploadFile(ctx context.Context, p UploadParams) (*File, error) {
+	if p.Reader == nil {
+		return nil, errors.New("reader is required")
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUploadForm(mw, p))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"files.upload", pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	res := &struct {
+		File File `json:"file"`
+	}{}
+	if _, err := c.do(req, "files.upload", res); err != nil {
+		return nil, err
+	}
+	return &res.File, nil
+}
+
+// writeUploadForm writes fields of files.upload followed by the file
+func writeUploadForm(mw *multipart.Writer, p UploadParams) error {
+	fields := [][2]string{
+		{"channels", strings.Join(p.Channels, ",")},
+		{"filename", p.Filename},
+		{"title", p.Title},
+		{"thread_ts", p.ThreadTS},
+		{"initial_comment", p.InitialComment},
+		{"filetype", p.Filetype},
+	}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	w, err := mw.CreateFormFile("file", p.Filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, p.Reader); err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	return mw.Close()
+}
+
+// UploadFileExternal uploads file by the external upload flow which replaces files.upload.
+// It gets upload URL by files.getUploadURLExternal, streams p.Reader to the URL and
+// shares the file by files.completeUploadExternal.
+func (c *Client) UploadFileExternal(ctx context.Context, p UploadParams) (*File, error) {
+	if p.Reader == nil {
+		return nil, errors.New("reader is required")
+	}
+	length := p.Length
+	if length == 0 {
+		var ok bool
+		if length, ok = readerLength(p.Reader); !ok {
+			return nil, ErrUnknownLength
+		}
+	}
+
+	q := url.Values{}
+	q.Set("filename", p.Filename)
+	q.Set("length", strconv.FormatInt(length, 10))
+	if p.Filetype != "" {
+		q.Set("snippet_type", p.Filetype)
+	}
+	upload := &struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}{}
+	if _, err := c.postForm(ctx, "files.getUploadURLExternal", q, upload); err != nil {
+		return nil, err
+	}
+
+	if err := c.uploadTo(ctx, upload.UploadURL, p.Reader, length); err != nil {
+		return nil, err
+	}
+
+	title := p.Title
+	if title == "" {
+		title = p.Filename
+	}
+	body := struct {
+		Files          []uploadedFile `json:"files"`
+		Channels       string         `json:"channels,omitempty"`
+		ThreadTS       string         `json:"thread_ts,omitempty"`
+		InitialComment string         `json:"initial_comment,omitempty"`
+	}{
+		Files:          []uploadedFile{{ID: upload.FileID, Title: title}},
+		Channels:       strings.Join(p.Channels, ","),
+		ThreadTS:       p.ThreadTS,
+		InitialComment: p.InitialComment,
+	}
+	res := &struct {
+		Files []File `json:"files"`
+	}{}
+	if _, err := c.postJSON(ctx, "files.completeUploadExternal", &body, res); err != nil {
+		return nil, err
+	}
+	if len(res.Files) == 0 {
+		return &File{ID: upload.FileID, Title: title}, nil
+	}
+	return &res.Files[0], nil
+}
+
+// uploadedFile is file completed by files.completeUploadExternal
+type uploadedFile struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// uploadTo streams r to upload URL given by files.getUploadURLExternal
+func (c *Client) uploadTo(ctx context.Context, uploadURL string, r io.Reader, length int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, ioutil.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxUploadResponseSize))
+		return fmt.Errorf("failed to upload file: status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// postForm calls method with form encoded body
+func (c *Client) postForm(ctx context.Context, method string, form url.Values, out interface{}) (*apiResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, method, out)
+}
+
+// readerLength gives remaining size of r when it's known without reading
+func readerLength(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len()), true
+	case *os.File:
+		st, err := v.Stat()
+		if err != nil || !st.Mode().IsRegular() {
+			return 0, false
+		}
+		off, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return st.Size() - off, true
+	}
+	return 0, false
+}
@@ -0,0 +1,170 @@
+package slackop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+const testCSV = "id,text\n1,Cloud Run\n2,Cloud Functions\n"
+
+// readMultipart gives fields and content of the file in multipart request
+func readMultipart(t *testing.T, req slacktest.Request) (map[string]string, string) {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("not multipart: %v", err)
+	}
+	mr := multipart.NewReader(bytes.NewReader(req.Body), params["boundary"])
+	fields := map[string]string{}
+	var content string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(part)
+		if part.FormName() == "file" {
+			content = string(b)
+			fields["file.filename"] = part.FileName()
+			continue
+		}
+		fields[part.FormName()] = string(b)
+	}
+	return fields, content
+}
+
+func TestUploadFile(t *testing.T) {
+	s, c := newTestClient(t)
+	f, err := c.UploadFile(context.Background(), UploadParams{
+		Reader:   strings.NewReader(testCSV),
+		Filename: "tweets.csv",
+		Title:    "Tweets",
+		Channels: []string{"C1", "C2"},
+		ThreadTS: "1.1",
+		Filetype: "csv",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID == "" || f.Filetype != "csv" || f.Size != int64(len(testCSV)) {
+		t.Errorf("got %+v", f)
+	}
+
+	req := s.RequestsOf("files.upload")[0]
+	if req.Header.Get("Content-Length") != "" {
+		t.Errorf("got content length %s, want streamed body", req.Header.Get("Content-Length"))
+	}
+	fields, content := readMultipart(t, req)
+	want := map[string]string{
+		"channels":      "C1,C2",
+		"filename":      "tweets.csv",
+		"title":         "Tweets",
+		"thread_ts":     "1.1",
+		"filetype":      "csv",
+		"file.filename": "tweets.csv",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("got %s=%q, want %q", k, fields[k], v)
+		}
+	}
+	if content != testCSV {
+		t.Errorf("got content %q", content)
+	}
+}
+
+func TestUploadFile_SlackError(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Inject("files.upload", slacktest.SlackError("not_in_channel"))
+	_, err := c.UploadFile(context.Background(), UploadParams{Reader: strings.NewReader(testCSV), Filename: "tweets.csv"})
+	if !errors.Is(err, ErrNotInChannel) {
+		t.Errorf("got %v, want ErrNotInChannel", err)
+	}
+}
+
+func TestUploadFileExternal(t *testing.T) {
+	s, c := newTestClient(t)
+
+	path := filepath.Join(t.TempDir(), "tweets.csv")
+	if err := ioutil.WriteFile(path, []byte(testCSV), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	f, err := c.UploadFileExternal(context.Background(), UploadParams{
+		Reader:         file,
+		Filename:       "tweets.csv",
+		Title:          "Tweets",
+		Channels:       []string{"C1"},
+		ThreadTS:       "1.1",
+		InitialComment: "collected tweets",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID == "" || f.Title != "Tweets" {
+		t.Errorf("got %+v", f)
+	}
+
+	form := string(s.RequestsOf("files.getUploadURLExternal")[0].Body)
+	if form != "filename=tweets.csv&length=38" {
+		t.Errorf("got form %s", form)
+	}
+	upload := s.RequestsOf(slacktest.MethodUpload)[0]
+	if string(upload.Body) != testCSV || upload.Header.Get("Content-Length") != "38" {
+		t.Errorf("got upload %q of length %s", upload.Body, upload.Header.Get("Content-Length"))
+	}
+	got := s.RequestsOf("files.completeUploadExternal")[0].Payload()
+	if got["channels"] != "C1" || got["thread_ts"] != "1.1" || got["initial_comment"] != "collected tweets" {
+		t.Errorf("got %v", got)
+	}
+	if files, _ := got["files"].([]interface{}); len(files) != 1 || files[0].(map[string]interface{})["id"] != f.ID {
+		t.Errorf("got files %v, want %s", got["files"], f.ID)
+	}
+}
+
+func TestUploadFileExternal_UploadError(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Inject(slacktest.MethodUpload, slacktest.ServerError(500))
+	_, err := c.UploadFileExternal(context.Background(), UploadParams{Reader: strings.NewReader(testCSV), Filename: "tweets.csv"})
+	if err == nil || len(s.RequestsOf("files.completeUploadExternal")) != 0 {
+		t.Errorf("got %v, want error before completion", err)
+	}
+}
+
+func TestUploadFileExternal_UnknownLength(t *testing.T) {
+	c := NewClient("xoxb-test")
+	r := io.MultiReader(strings.NewReader(testCSV))
+	if _, err := c.UploadFileExternal(context.Background(), UploadParams{Reader: r, Filename: "tweets.csv"}); err != ErrUnknownLength {
+		t.Errorf("got %v, want ErrUnknownLength", err)
+	}
+}
+
+func TestReaderLength(t *testing.T) {
+	br := bytes.NewReader([]byte(testCSV))
+	br.ReadByte()
+	if n, ok := readerLength(br); !ok || n != int64(len(testCSV)-1) {
+		t.Errorf("got %d %v, want remaining length", n, ok)
+	}
+	if _, ok := readerLength(io.LimitReader(br, 3)); ok {
+		t.Error("got length of LimitReader")
+	}
+}
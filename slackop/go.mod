@@ -1,3 +1,7 @@
 module github.com/fckey/go-sandbox/slackop
 
-go 1.12
+go 1.15
+
+require github.com/fckey/go-sandbox/retry v0.0.0-00010101000000-000000000000
+
+replace github.com/fckey/go-sandbox/retry => ../retry
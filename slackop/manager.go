@@ -2,59 +2,175 @@ package slackop
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
 )
 
-// payload to send to Slack API
-type payload struct {
-	text string `json:"text"`
-	name string `json:name`
-}
+// DefaultTimeout is timeout of NotifyContext including retries when WithTimeout is not given
+const DefaultTimeout = 10 * time.Second
+
+// webhookMethod is name of the method reported in APIError of incoming webhook
+const webhookMethod = "webhook"
 
-// Target contains Slack API endpoint and message to be sent
+// maxErrorBodySize is size of the response body read to find Slack error code
+const maxErrorBodySize = 1024
+
+// Target contains Slack API endpoint and message to be sent.
+// Message is sent when it's given, otherwise Text is sent as plain message.
 type Target struct {
-	URL  string
-	text string
+	URL     string
+	Text    string
+	Message *Message
 }
 
-// Manager keeps context to interact with slack
+// Manager keeps context to interact with slack.
+// Manager given as literal such as &Manager{URL: url} works with default setting.
 type Manager struct {
-	URL string
+	URL        string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      *retry.Config
+	userAgent  string
+}
+
+// Option configures Manager
+type Option func(*Manager)
+
+// WithHTTPClient sets http.Client used to call the webhook
+func WithHTTPClient(c *http.Client) Option {
+	return func(mgr *Manager) {
+		mgr.httpClient = c
+	}
+}
+
+// WithTimeout sets timeout of NotifyContext including retries
+func WithTimeout(d time.Duration) Option {
+	return func(mgr *Manager) {
+		mgr.timeout = d
+	}
+}
+
+// WithRetry retries the webhook call on 5xx, 429 and network errors according to cfg
+func WithRetry(cfg retry.Config) Option {
+	return func(mgr *Manager) {
+		mgr.retry = &cfg
+	}
+}
+
+// WithUserAgent sets User-Agent header of the webhook call
+func WithUserAgent(ua string) Option {
+	return func(mgr *Manager) {
+		mgr.userAgent = ua
+	}
+}
+
+// NewManager gives Manager sending messages to incoming webhook URL
+func NewManager(url string, opts ...Option) *Manager {
+	mgr := &Manager{URL: url}
+	for _, opt := range opts {
+		opt(mgr)
+	}
+	return mgr
 }
 
-// Notify send text to URL in Target
+// Notify send text or message to URL in Target
 func Notify(tg Target) error {
-	msg := payload{
-		text: tg.text,
+	msg := tg.Message
+	if msg == nil {
+		msg = &Message{Text: tg.Text}
 	}
-	m, err := json.Marshal(msg)
+	return NewManager(tg.URL).NotifyContext(context.Background(), msg)
+}
+
+// Notify sends text to URL of the manager
+func (mgr *Manager) Notify(text string) error {
+	return mgr.NotifyContext(context.Background(), &Message{Text: text})
+}
+
+// NotifyMessage sends msg to URL of the manager
+func (mgr *Manager) NotifyMessage(msg *Message) error {
+	return mgr.NotifyContext(context.Background(), msg)
+}
+
+// NotifyContext sends msg to URL of the manager.
+// Error is *APIError when Slack responds non-2xx status, and it matches with
+// errors such as ErrInvalidPayload or ErrChannelNotFound by errors.Is.
+func (mgr *Manager) NotifyContext(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg)
 	if err != nil {
-		log.Fatal("Failed to marchall msg: ", err)
-		return err
+		return fmt.Errorf("failed to marshal msg: %v", err)
 	}
-	req, err := http.NewRequest(
-		"POST",
-		tg.URL,
-		bytes.NewBuffer(m),
-	)
-	if err != nil {
-		log.Fatal("Failed to create request: ", err)
-		return err
+	timeout := mgr.timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
+	if mgr.retry == nil {
+		return mgr.post(ctx, body)
+	}
+	return retry.RunWithRetry(ctx, *mgr.retry, func() error {
+		return mgr.post(ctx, body)
+	}, isRetryable)
+}
+
+// post sends body to the webhook once
+func (mgr *Manager) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mgr.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	c := &http.Client{}
-	resp, err := c.Do(req)
-	defer resp.Body.Close()
+	if mgr.userAgent != "" {
+		req.Header.Set("User-Agent", mgr.userAgent)
+	}
+	resp, err := mgr.client().Do(req)
 	if err != nil {
-		log.Fatal("Failed to POST msg: ", err)
 		return err
 	}
-	return nil
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return newAPIError(webhookMethod, resp, errorCode(b))
 }
 
-func (mgr *Manager) Notify(text string) error {
-	return Notify(Target{URL: mgr.URL, text: text})
+func (mgr *Manager) client() *http.Client {
+	if mgr.httpClient != nil {
+		return mgr.httpClient
+	}
+	return http.DefaultClient
+}
+
+// errorCode gives Slack error code such as "invalid_payload" in plain text body of webhook response
+func errorCode(body []byte) string {
+	code := strings.TrimSpace(string(body))
+	if code == "" || strings.ContainsAny(code, " \t\n{<") {
+		return ""
+	}
+	return code
+}
+
+// isRetryable judges if failure of Slack call is temporary
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
 }
@@ -0,0 +1,142 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+// newTestWebhook starts fake Slack for incoming webhook
+func newTestWebhook(t *testing.T) *slacktest.Server {
+	t.Helper()
+	s := slacktest.NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestManager_NotifyContext(t *testing.T) {
+	s := newTestWebhook(t)
+
+	mgr := NewManager(s.WebhookURL(), WithUserAgent("crunsample/1.0"))
+	if err := mgr.Notify("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := s.RequestsOf(slacktest.MethodWebhook)[0]
+	if got := req.Payload(); got["text"] != "hello" {
+		t.Errorf("text: got %v, want hello", got["text"])
+	}
+	if ua := req.Header.Get("User-Agent"); ua != "crunsample/1.0" {
+		t.Errorf("user agent: got %s, want crunsample/1.0", ua)
+	}
+}
+
+func TestManager_NotifyContext_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  slacktest.Fault
+		body   string
+		status int
+		want   error
+	}{
+		{name: "invalid payload", fault: slacktest.SlackError("invalid_payload"), status: http.StatusBadRequest, want: ErrInvalidPayload},
+		{name: "channel not found", fault: slacktest.SlackError("channel_not_found"), status: http.StatusNotFound, want: ErrChannelNotFound},
+		{name: "channel is archived", fault: slacktest.SlackError("channel_is_archived"), status: http.StatusGone, want: ErrIsArchived},
+		{name: "rate limited", fault: slacktest.RateLimited(time.Second), status: http.StatusTooManyRequests, want: ErrRateLimited},
+		{name: "server error", fault: slacktest.ServerError(http.StatusBadGateway), status: http.StatusBadGateway},
+		{name: "html body", body: "<html>bad gateway</html>", status: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var url string
+			if tt.body != "" {
+				s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+				}))
+				defer s.Close()
+				url = s.URL
+			} else {
+				s := newTestWebhook(t)
+				s.Inject(slacktest.MethodWebhook, tt.fault)
+				url = s.WebhookURL()
+			}
+
+			err := NewManager(url).NotifyContext(context.Background(), &Message{Text: "hello"})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status: got %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && apiErr.Code != "" {
+				t.Errorf("code: got %q, want empty", apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestManager_NotifyContext_NoText(t *testing.T) {
+	s := newTestWebhook(t)
+	err := NewManager(s.WebhookURL()).NotifyContext(context.Background(), &Message{})
+	if !errors.Is(err, ErrNoText) {
+		t.Errorf("got %v, want %v", err, ErrNoText)
+	}
+}
+
+func TestManager_NotifyContext_Retry(t *testing.T) {
+	s := newTestWebhook(t)
+	s.Inject(slacktest.MethodWebhook,
+		slacktest.ServerError(http.StatusServiceUnavailable),
+		slacktest.ServerError(http.StatusServiceUnavailable))
+
+	mgr := NewManager(s.WebhookURL(), WithRetry(retry.NewConfig(time.Millisecond, 10*time.Millisecond, 2, 5)))
+	if err := mgr.NotifyContext(context.Background(), &Message{Text: "hello"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(s.RequestsOf(slacktest.MethodWebhook)); n != 3 {
+		t.Errorf("n: got %d, want %d", n, 3)
+	}
+}
+
+func TestManager_NotifyContext_RetryExhausted(t *testing.T) {
+	s := newTestWebhook(t)
+	s.Inject(slacktest.MethodWebhook, slacktest.RateLimited(0), slacktest.RateLimited(0), slacktest.RateLimited(0))
+
+	mgr := NewManager(s.WebhookURL(), WithRetry(retry.NewConfig(time.Millisecond, 10*time.Millisecond, 2, 3)))
+	err := mgr.NotifyContext(context.Background(), &Message{Text: "hello"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got %v, want *APIError", err)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v, want %v", err, ErrRateLimited)
+	}
+	if n := len(s.RequestsOf(slacktest.MethodWebhook)); n != 3 {
+		t.Errorf("n: got %d, want %d", n, 3)
+	}
+}
+
+func TestManager_NotifyContext_Timeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer s.Close()
+
+	err := NewManager(s.URL, WithTimeout(10*time.Millisecond)).Notify("hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,250 @@
+package slackop
+
+// Message is payload sent to Slack.
+// Text is used as fallback of notification when Blocks are given.
+// See https://api.slack.com/reference/block-kit
+type Message struct {
+	Text           string       `json:"text,omitempty"`
+	Username       string       `json:"username,omitempty"`
+	IconEmoji      string       `json:"icon_emoji,omitempty"`
+	IconURL        string       `json:"icon_url,omitempty"`
+	Channel        string       `json:"channel,omitempty"`
+	ThreadTS       string       `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool         `json:"reply_broadcast,omitempty"`
+	Blocks         []Block      `json:"blocks,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+}
+
+// Block types of Block Kit
+const (
+	BlockTypeSection = "section"
+	BlockTypeDivider = "divider"
+	BlockTypeContext = "context"
+	BlockTypeActions = "actions"
+	BlockTypeImage   = "image"
+	BlockTypeHeader  = "header"
+)
+
+// Element types used in blocks
+const (
+	ElementTypeButton = "button"
+	ElementTypeImage  = "image"
+)
+
+// Text object types
+const (
+	TextTypePlain    = "plain_text"
+	TextTypeMarkdown = "mrkdwn"
+)
+
+// Button styles. Default style is given by empty string
+const (
+	ButtonStylePrimary = "primary"
+	ButtonStyleDanger  = "danger"
+)
+
+// Block is a layout block of Block Kit
+type Block interface {
+	BlockType() string
+}
+
+// Element is an interactive or image element placed in blocks
+type Element interface {
+	ElementType() string
+}
+
+// ContextElement is an element which can be placed in ContextBlock.
+// TextObject and ImageElement satisfy this.
+type ContextElement interface {
+	contextElement()
+}
+
+// TextObject is text composition object
+type TextObject struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Emoji    bool   `json:"emoji,omitempty"`
+	Verbatim bool   `json:"verbatim,omitempty"`
+}
+
+// PlainText gives plain_text object
+func PlainText(text string) *TextObject {
+	return &TextObject{Type: TextTypePlain, Text: text}
+}
+
+// Markdown gives mrkdwn object
+func Markdown(text string) *TextObject {
+	return &TextObject{Type: TextTypeMarkdown, Text: text}
+}
+
+func (*TextObject) contextElement() {}
+
+// SectionBlock shows text and fields with an optional accessory
+type SectionBlock struct {
+	Type      string        `json:"type"`
+	BlockID   string        `json:"block_id,omitempty"`
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory Element       `json:"accessory,omitempty"`
+}
+
+// NewSectionBlock gives section having text and fields
+func NewSectionBlock(text *TextObject, fields ...*TextObject) *SectionBlock {
+	return &SectionBlock{Type: BlockTypeSection, Text: text, Fields: fields}
+}
+
+// BlockType implements Block
+func (*SectionBlock) BlockType() string { return BlockTypeSection }
+
+// DividerBlock is a horizontal line
+type DividerBlock struct {
+	Type    string `json:"type"`
+	BlockID string `json:"block_id,omitempty"`
+}
+
+// NewDividerBlock gives divider
+func NewDividerBlock() *DividerBlock {
+	return &DividerBlock{Type: BlockTypeDivider}
+}
+
+// BlockType implements Block
+func (*DividerBlock) BlockType() string { return BlockTypeDivider }
+
+// ContextBlock shows small texts and images
+type ContextBlock struct {
+	Type     string           `json:"type"`
+	BlockID  string           `json:"block_id,omitempty"`
+	Elements []ContextElement `json:"elements"`
+}
+
+// NewContextBlock gives context having elements
+func NewContextBlock(elements ...ContextElement) *ContextBlock {
+	return &ContextBlock{Type: BlockTypeContext, Elements: elements}
+}
+
+// BlockType implements Block
+func (*ContextBlock) BlockType() string { return BlockTypeContext }
+
+// ActionsBlock holds interactive elements such as buttons
+type ActionsBlock struct {
+	Type     string    `json:"type"`
+	BlockID  string    `json:"block_id,omitempty"`
+	Elements []Element `json:"elements"`
+}
+
+// NewActionsBlock gives actions having elements
+func NewActionsBlock(elements ...Element) *ActionsBlock {
+	return &ActionsBlock{Type: BlockTypeActions, Elements: elements}
+}
+
+// BlockType implements Block
+func (*ActionsBlock) BlockType() string { return BlockTypeActions }
+
+// ImageBlock shows an image
+type ImageBlock struct {
+	Type     string      `json:"type"`
+	BlockID  string      `json:"block_id,omitempty"`
+	ImageURL string      `json:"image_url"`
+	AltText  string      `json:"alt_text"`
+	Title    *TextObject `json:"title,omitempty"`
+}
+
+// NewImageBlock gives image block
+func NewImageBlock(imageURL, altText string) *ImageBlock {
+	return &ImageBlock{Type: BlockTypeImage, ImageURL: imageURL, AltText: altText}
+}
+
+// BlockType implements Block
+func (*ImageBlock) BlockType() string { return BlockTypeImage }
+
+// HeaderBlock shows plain text in large font
+type HeaderBlock struct {
+	Type    string      `json:"type"`
+	BlockID string      `json:"block_id,omitempty"`
+	Text    *TextObject `json:"text"`
+}
+
+// NewHeaderBlock gives header block
+func NewHeaderBlock(text string) *HeaderBlock {
+	return &HeaderBlock{Type: BlockTypeHeader, Text: PlainText(text)}
+}
+
+// BlockType implements Block
+func (*HeaderBlock) BlockType() string { return BlockTypeHeader }
+
+// ButtonElement is a button which opens URL or sends action to the app
+type ButtonElement struct {
+	Type     string      `json:"type"`
+	Text     *TextObject `json:"text"`
+	ActionID string      `json:"action_id,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Style    string      `json:"style,omitempty"`
+}
+
+// NewButton gives button labeled by text
+func NewButton(actionID, text string) *ButtonElement {
+	return &ButtonElement{Type: ElementTypeButton, ActionID: actionID, Text: PlainText(text)}
+}
+
+// WithURL sets URL opened by the button
+func (b *ButtonElement) WithURL(url string) *ButtonElement {
+	b.URL = url
+	return b
+}
+
+// WithValue sets value sent with the action
+func (b *ButtonElement) WithValue(value string) *ButtonElement {
+	b.Value = value
+	return b
+}
+
+// WithStyle sets style such as ButtonStylePrimary
+func (b *ButtonElement) WithStyle(style string) *ButtonElement {
+	b.Style = style
+	return b
+}
+
+// ElementType implements Element
+func (*ButtonElement) ElementType() string { return ElementTypeButton }
+
+// ImageElement is an image placed in section accessory or context
+type ImageElement struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+// NewImageElement gives image element
+func NewImageElement(imageURL, altText string) *ImageElement {
+	return &ImageElement{Type: ElementTypeImage, ImageURL: imageURL, AltText: altText}
+}
+
+// ElementType implements Element
+func (*ImageElement) ElementType() string { return ElementTypeImage }
+
+func (*ImageElement) contextElement() {}
+
+// Attachment is secondary content shown with colored bar
+type Attachment struct {
+	Color      string            `json:"color,omitempty"`
+	Fallback   string            `json:"fallback,omitempty"`
+	Pretext    string            `json:"pretext,omitempty"`
+	AuthorName string            `json:"author_name,omitempty"`
+	AuthorLink string            `json:"author_link,omitempty"`
+	Title      string            `json:"title,omitempty"`
+	TitleLink  string            `json:"title_link,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	Fields     []AttachmentField `json:"fields,omitempty"`
+	ImageURL   string            `json:"image_url,omitempty"`
+	Footer     string            `json:"footer,omitempty"`
+	Ts         int64             `json:"ts,omitempty"`
+	Blocks     []Block           `json:"blocks,omitempty"`
+}
+
+// AttachmentField is a table-like field in Attachment
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
@@ -0,0 +1,189 @@
+package slackop
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// typeOf reads "type" field of JSON object
+func typeOf(raw json.RawMessage) (string, error) {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	return v.Type, nil
+}
+
+// decodeBlocks decodes JSON array of blocks into typed blocks
+func decodeBlocks(raws []json.RawMessage) ([]Block, error) {
+	if raws == nil {
+		return nil, nil
+	}
+	blocks := make([]Block, 0, len(raws))
+	for i, raw := range raws {
+		t, err := typeOf(raw)
+		if err != nil {
+			return nil, fmt.Errorf("blocks[%d]: %v", i, err)
+		}
+		var b Block
+		switch t {
+		case BlockTypeSection:
+			b = &SectionBlock{}
+		case BlockTypeDivider:
+			b = &DividerBlock{}
+		case BlockTypeContext:
+			b = &ContextBlock{}
+		case BlockTypeActions:
+			b = &ActionsBlock{}
+		case BlockTypeImage:
+			b = &ImageBlock{}
+		case BlockTypeHeader:
+			b = &HeaderBlock{}
+		default:
+			return nil, fmt.Errorf("blocks[%d]: unknown block type %q", i, t)
+		}
+		if err := json.Unmarshal(raw, b); err != nil {
+			return nil, fmt.Errorf("blocks[%d]: %v", i, err)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// decodeElement decodes JSON object of element into typed element
+func decodeElement(raw json.RawMessage) (Element, error) {
+	t, err := typeOf(raw)
+	if err != nil {
+		return nil, err
+	}
+	var e Element
+	switch t {
+	case ElementTypeButton:
+		e = &ButtonElement{}
+	case ElementTypeImage:
+		e = &ImageElement{}
+	default:
+		return nil, fmt.Errorf("unknown element type %q", t)
+	}
+	if err := json.Unmarshal(raw, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UnmarshalJSON decodes blocks and attachments into typed values
+func (m *Message) UnmarshalJSON(b []byte) error {
+	type alias Message
+	var v struct {
+		alias
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	blocks, err := decodeBlocks(v.Blocks)
+	if err != nil {
+		return err
+	}
+	*m = Message(v.alias)
+	m.Blocks = blocks
+	return nil
+}
+
+// UnmarshalJSON decodes blocks in attachment into typed values
+func (a *Attachment) UnmarshalJSON(b []byte) error {
+	type alias Attachment
+	var v struct {
+		alias
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	blocks, err := decodeBlocks(v.Blocks)
+	if err != nil {
+		return err
+	}
+	*a = Attachment(v.alias)
+	a.Blocks = blocks
+	return nil
+}
+
+// UnmarshalJSON decodes accessory into typed element
+func (s *SectionBlock) UnmarshalJSON(b []byte) error {
+	type alias SectionBlock
+	var v struct {
+		alias
+		Accessory json.RawMessage `json:"accessory"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = SectionBlock(v.alias)
+	if len(v.Accessory) > 0 {
+		e, err := decodeElement(v.Accessory)
+		if err != nil {
+			return fmt.Errorf("accessory: %v", err)
+		}
+		s.Accessory = e
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes elements into texts and images
+func (c *ContextBlock) UnmarshalJSON(b []byte) error {
+	type alias ContextBlock
+	var v struct {
+		alias
+		Elements []json.RawMessage `json:"elements"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*c = ContextBlock(v.alias)
+	c.Elements = nil
+	for i, raw := range v.Elements {
+		t, err := typeOf(raw)
+		if err != nil {
+			return fmt.Errorf("elements[%d]: %v", i, err)
+		}
+		var e ContextElement
+		switch t {
+		case TextTypePlain, TextTypeMarkdown:
+			e = &TextObject{}
+		case ElementTypeImage:
+			e = &ImageElement{}
+		default:
+			return fmt.Errorf("elements[%d]: unknown context element type %q", i, t)
+		}
+		if err := json.Unmarshal(raw, e); err != nil {
+			return fmt.Errorf("elements[%d]: %v", i, err)
+		}
+		c.Elements = append(c.Elements, e)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes elements into typed elements
+func (a *ActionsBlock) UnmarshalJSON(b []byte) error {
+	type alias ActionsBlock
+	var v struct {
+		alias
+		Elements []json.RawMessage `json:"elements"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*a = ActionsBlock(v.alias)
+	a.Elements = nil
+	for i, raw := range v.Elements {
+		e, err := decodeElement(raw)
+		if err != nil {
+			return fmt.Errorf("elements[%d]: %v", i, err)
+		}
+		a.Elements = append(a.Elements, e)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package slackop
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// assertGolden compares JSON of v with testdata/name.golden.json
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+func TestMessageBuilder_Golden(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *MessageBuilder
+	}{
+		{
+			name: "text",
+			b:    NewMessageBuilder("Hello"),
+		},
+		{
+			name: "blocks",
+			b: NewMessageBuilder("3 new tweets for 'Cloud Google'").
+				Username("crunsample").
+				IconEmoji(":bird:").
+				Header("Cloud Google").
+				Section("*3* new tweets").
+				Fields("*Retweets*\n12", "*Favorites*\n40").
+				Divider().
+				Context("collected by crunsample").
+				Image("https://example.com/chart.png", "chart").
+				Actions(
+					NewButton("open", "Open").WithURL("https://twitter.com/search?q=Cloud%20Google").WithStyle(ButtonStylePrimary),
+					NewButton("mute", "Mute").WithValue("Cloud Google").WithStyle(ButtonStyleDanger),
+				),
+		},
+		{
+			name: "attachments",
+			b: NewMessageBuilder("Polling failed").
+				Attachment(Attachment{
+					Color:    "#d50200",
+					Fallback: "Polling failed",
+					Title:    "Twitter API",
+					Text:     "Response code is 429",
+					Fields: []AttachmentField{
+						{Title: "Keyword", Value: "Cloud Google", Short: true},
+						{Title: "Retry", Value: "3", Short: true},
+					},
+					Blocks: []Block{NewSectionBlock(Markdown("See logs"))},
+				}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.b.Build()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, "message_"+tt.name, msg)
+		})
+	}
+}
+
+func TestMessage_Validate(t *testing.T) {
+	tooManyBlocks := NewMessageBuilder("many")
+	for i := 0; i < MaxBlocks+1; i++ {
+		tooManyBlocks.Divider()
+	}
+	tests := []struct {
+		name string
+		b    *MessageBuilder
+		want string
+	}{
+		{
+			name: "empty",
+			b:    NewMessageBuilder(""),
+			want: "text: must not be empty",
+		},
+		{
+			name: "too many blocks",
+			b:    tooManyBlocks,
+			want: "blocks: count 51 exceeds 50",
+		},
+		{
+			name: "long section",
+			b:    NewMessageBuilder("long").Section(strings.Repeat("a", MaxSectionTextLength+1)),
+			want: "blocks[0].text.text: length 3001 exceeds 3000",
+		},
+		{
+			name: "too many fields",
+			b:    NewMessageBuilder("fields").Fields(strings.Split("a,b,c,d,e,f,g,h,i,j,k", ",")...),
+			want: "blocks[0].fields: count 11 exceeds 10",
+		},
+		{
+			name: "long button",
+			b:    NewMessageBuilder("button").Actions(NewButton("id", strings.Repeat("b", MaxButtonTextLength+1))),
+			want: "blocks[0].elements[0].text.text: length 76 exceeds 75",
+		},
+		{
+			name: "header in mrkdwn",
+			b:    NewMessageBuilder("header").Block(&HeaderBlock{Type: BlockTypeHeader, Text: Markdown("*h*")}),
+			want: "blocks[0].text.type: must be plain_text",
+		},
+		{
+			name: "image without alt",
+			b:    NewMessageBuilder("image").Attachment(Attachment{Blocks: []Block{NewImageBlock("https://example.com/a.png", "")}}),
+			want: "attachments[0].blocks[0].alt_text: must not be empty",
+		},
+		{
+			name: "nil block",
+			b:    NewMessageBuilder("nil").Block((*SectionBlock)(nil)),
+			want: "blocks[0]: must not be nil",
+		},
+		{
+			name: "nil accessory",
+			b:    NewMessageBuilder("nil").Block(&SectionBlock{Type: BlockTypeSection, Text: PlainText("s"), Accessory: (*ButtonElement)(nil)}),
+			want: "blocks[0].accessory: must not be nil",
+		},
+		{
+			name: "nil element",
+			b:    NewMessageBuilder("nil").Actions((*ButtonElement)(nil)),
+			want: "blocks[0].elements[0]: must not be nil",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.b.Build()
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("got %v, want *ValidationError", err)
+			}
+			if !strings.Contains(verr.Error(), tt.want) {
+				t.Errorf("got %q, want to contain %q", verr.Error(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package slackop
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDeliveredRetention is how long IDs of delivered messages are kept to drop duplicates
+const DefaultDeliveredRetention = 24 * time.Hour
+
+// OutboxEntry is message persisted until it's delivered
+type OutboxEntry struct {
+	ID      string    `json:"id"`
+	Dest    string    `json:"dest"`
+	Msg     *Message  `json:"msg"`
+	Created time.Time `json:"created"`
+}
+
+// OutboxStore persists entries of Outbox
+type OutboxStore interface {
+	// Add persists e as pending. It reports false without error when
+	// entry of the same ID is pending or was delivered.
+	Add(e OutboxEntry) (bool, error)
+	// MarkDelivered removes entry of id from pending and remembers its ID
+	MarkDelivered(id string) error
+	// Pending gives entries not delivered yet in added order
+	Pending() ([]OutboxEntry, error)
+	Close() error
+}
+
+// Outbox persists messages before sending them by Queue, and replays the ones
+// not delivered on startup, so that notifications survive restart of the instance.
+// Delivery is at-least-once: message sent right before the crash can be sent again
+// because it's marked as delivered after Slack accepted it.
+type Outbox struct {
+	store OutboxStore
+	queue *Queue
+	now   func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]bool
+}
+
+// NewOutbox starts Queue delivering by send and enqueues pending entries of store
+func NewOutbox(ctx context.Context, store OutboxStore, send SenderFunc, opts ...QueueOption) (*Outbox, error) {
+	o := &Outbox{
+		store:    store,
+		now:      time.Now,
+		inflight: map[string]bool{},
+	}
+	o.queue = NewQueue(send, opts...)
+	o.queue.onDelivered = o.delivered
+	onError := o.queue.onError
+	o.queue.onError = func(d Delivery, err error) {
+		o.release(d.ID)
+		onError(d, err)
+	}
+	if _, err := o.Replay(ctx); err != nil {
+		o.queue.Close(ctx)
+		return nil, err
+	}
+	return o, nil
+}
+
+// Enqueue persists msg and adds it to the queue.
+// id is dedup ID such as tweet ID; message of the ID which is pending or was delivered is ignored.
+// Random ID is given when id is empty. Message persisted but rejected by the queue such as by
+// ErrQueueFull is sent by Replay or on the next start.
+func (o *Outbox) Enqueue(ctx context.Context, id, dest string, msg *Message) error {
+	if id == "" {
+		var err error
+		if id, err = newOutboxID(); err != nil {
+			return err
+		}
+	}
+	added, err := o.store.Add(OutboxEntry{ID: id, Dest: dest, Msg: msg, Created: o.now()})
+	if err != nil {
+		return fmt.Errorf("failed to persist message %s: %v", id, err)
+	}
+	if !added {
+		return nil
+	}
+	return o.enqueue(ctx, Delivery{ID: id, Dest: dest, Msg: msg})
+}
+
+// Replay enqueues pending entries which are not in the queue such as ones failed before.
+// It gives number of the enqueued entries.
+func (o *Outbox) Replay(ctx context.Context) (int, error) {
+	entries, err := o.store.Pending()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	n := 0
+	for _, e := range entries {
+		err := o.enqueue(ctx, Delivery{ID: e.ID, Dest: e.Dest, Msg: e.Msg})
+		switch err {
+		case nil:
+		case errInflight:
+			continue
+		case ErrQueueFull:
+			// The rest stays pending for the next replay
+			return n, nil
+		default:
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Flush waits until every enqueued message is finished
+func (o *Outbox) Flush(ctx context.Context) error {
+	return o.queue.Flush(ctx)
+}
+
+// Close stops the queue and closes the store. Messages not delivered are replayed on the next start.
+func (o *Outbox) Close(ctx context.Context) error {
+	err := o.queue.Close(ctx)
+	if cerr := o.store.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// errInflight tells that the entry is already in the queue
+var errInflight = errors.New("slackop: message is in queue")
+
+func (o *Outbox) enqueue(ctx context.Context, d Delivery) error {
+	o.mu.Lock()
+	if o.inflight[d.ID] {
+		o.mu.Unlock()
+		return errInflight
+	}
+	o.inflight[d.ID] = true
+	o.mu.Unlock()
+	if err := o.queue.enqueue(ctx, d); err != nil {
+		o.release(d.ID)
+		return err
+	}
+	return nil
+}
+
+// delivered marks d as delivered in the store
+func (o *Outbox) delivered(d Delivery) {
+	if err := o.store.MarkDelivered(d.ID); err != nil {
+		o.queue.onError(d, fmt.Errorf("failed to mark message %s delivered: %v", d.ID, err))
+		return
+	}
+	o.release(d.ID)
+}
+
+func (o *Outbox) release(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.inflight, id)
+}
+
+// newOutboxID gives random ID
+func newOutboxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MemoryOutbox is OutboxStore in memory for tests and for instances which can lose messages.
+// IDs of delivered messages are kept for DefaultDeliveredRetention.
+type MemoryOutbox struct {
+	mu        sync.Mutex
+	pending   []OutboxEntry
+	delivered *ttlCache
+}
+
+// NewMemoryOutbox gives empty MemoryOutbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{delivered: newTTLCache(DefaultDeliveredRetention)}
+}
+
+// Add implements OutboxStore
+func (m *MemoryOutbox) Add(e OutboxEntry) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.delivered.has(e.ID) || indexOfEntry(m.pending, e.ID) >= 0 {
+		return false, nil
+	}
+	m.pending = append(m.pending, e)
+	return true, nil
+}
+
+// MarkDelivered implements OutboxStore
+func (m *MemoryOutbox) MarkDelivered(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := indexOfEntry(m.pending, id); i >= 0 {
+		m.pending = append(m.pending[:i], m.pending[i+1:]...)
+	}
+	m.delivered.add(id)
+	return nil
+}
+
+// Pending implements OutboxStore
+func (m *MemoryOutbox) Pending() ([]OutboxEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]OutboxEntry(nil), m.pending...), nil
+}
+
+// Close implements OutboxStore
+func (m *MemoryOutbox) Close() error {
+	return nil
+}
+
+func indexOfEntry(entries []OutboxEntry, id string) int {
+	for i, e := range entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
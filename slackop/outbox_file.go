@@ -0,0 +1,264 @@
+package slackop
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Operations of records in FileOutbox
+const (
+	outboxOpAdd       = "add"
+	outboxOpDelivered = "delivered"
+)
+
+// maxOutboxRecordSize is maximum size of a line of FileOutbox.
+// Longer line can't be written by FileOutbox and is skipped on load.
+const maxOutboxRecordSize = 4 << 20
+
+// outboxRecord is a line of FileOutbox
+type outboxRecord struct {
+	Op    string       `json:"op"`
+	Entry *OutboxEntry `json:"entry,omitempty"`
+	ID    string       `json:"id,omitempty"`
+	Time  time.Time    `json:"time,omitempty"`
+}
+
+// FileOutbox is OutboxStore of append-only file having a JSON record per line.
+// Every record is synced to disk before Add and MarkDelivered return.
+// The file is compacted on open and by MarkDelivered once per retention by dropping
+// delivered entries and IDs delivered before the retention.
+type FileOutbox struct {
+	path      string
+	retention time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	f           *os.File
+	pending     []OutboxEntry
+	delivered   map[string]time.Time
+	nextCompact time.Time
+}
+
+// FileOutboxOption configures FileOutbox
+type FileOutboxOption func(*FileOutbox)
+
+// WithDeliveredRetention sets how long IDs of delivered messages are kept to drop duplicates
+func WithDeliveredRetention(d time.Duration) FileOutboxOption {
+	return func(fo *FileOutbox) {
+		fo.retention = d
+	}
+}
+
+// OpenFileOutbox opens or creates FileOutbox at path.
+// Record broken by crash in the middle of writing or longer than the limit is ignored.
+func OpenFileOutbox(path string, opts ...FileOutboxOption) (*FileOutbox, error) {
+	fo := &FileOutbox{
+		path:      path,
+		retention: DefaultDeliveredRetention,
+		now:       time.Now,
+		delivered: map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(fo)
+	}
+	if err := fo.load(); err != nil {
+		return nil, err
+	}
+	if err := fo.compact(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fo.f = f
+	return fo, nil
+}
+
+// load reads records of the file
+func (fo *FileOutbox) load() error {
+	f, err := os.Open(fo.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = readLines(f, maxOutboxRecordSize, func(line []byte) {
+		var r outboxRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return
+		}
+		switch r.Op {
+		case outboxOpAdd:
+			if r.Entry != nil && indexOfEntry(fo.pending, r.Entry.ID) < 0 {
+				fo.pending = append(fo.pending, *r.Entry)
+			}
+		case outboxOpDelivered:
+			if i := indexOfEntry(fo.pending, r.ID); i >= 0 {
+				fo.pending = append(fo.pending[:i], fo.pending[i+1:]...)
+			}
+			fo.delivered[r.ID] = r.Time
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", fo.path, err)
+	}
+	return nil
+}
+
+// readLines calls f with each line of r. Lines longer than max bytes are skipped.
+func readLines(r io.Reader, max int, f func(line []byte)) error {
+	br := bufio.NewReader(r)
+	var line []byte
+	skip := false
+	for {
+		chunk, more, err := br.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !skip {
+			line = append(line, chunk...)
+			skip = len(line) > max
+		}
+		if more {
+			continue
+		}
+		if !skip {
+			f(line)
+		}
+		line, skip = line[:0], false
+	}
+}
+
+// compact rewrites the file with pending entries and delivered IDs within retention
+func (fo *FileOutbox) compact() error {
+	fo.nextCompact = fo.now().Add(fo.retention)
+	tmp, err := ioutil.TempFile(filepath.Dir(fo.path), filepath.Base(fo.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for i := range fo.pending {
+		if err := enc.Encode(outboxRecord{Op: outboxOpAdd, Entry: &fo.pending[i]}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for id, t := range fo.delivered {
+		if fo.expired(t) {
+			delete(fo.delivered, id)
+			continue
+		}
+		if err := enc.Encode(outboxRecord{Op: outboxOpDelivered, ID: id, Time: t}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fo.path)
+}
+
+// Add implements OutboxStore
+func (fo *FileOutbox) Add(e OutboxEntry) (bool, error) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	if t, ok := fo.delivered[e.ID]; ok && !fo.expired(t) || indexOfEntry(fo.pending, e.ID) >= 0 {
+		return false, nil
+	}
+	if err := fo.write(outboxRecord{Op: outboxOpAdd, Entry: &e}); err != nil {
+		return false, err
+	}
+	fo.pending = append(fo.pending, e)
+	return true, nil
+}
+
+// MarkDelivered implements OutboxStore
+func (fo *FileOutbox) MarkDelivered(id string) error {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	now := fo.now()
+	if err := fo.write(outboxRecord{Op: outboxOpDelivered, ID: id, Time: now}); err != nil {
+		return err
+	}
+	if i := indexOfEntry(fo.pending, id); i >= 0 {
+		fo.pending = append(fo.pending[:i], fo.pending[i+1:]...)
+	}
+	fo.delivered[id] = now
+	if now.After(fo.nextCompact) {
+		return fo.rotate()
+	}
+	return nil
+}
+
+// rotate compacts the file and switches writing to the compacted one
+func (fo *FileOutbox) rotate() error {
+	if err := fo.compact(); err != nil {
+		return fmt.Errorf("failed to compact %s: %v", fo.path, err)
+	}
+	f, err := os.OpenFile(fo.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fo.f.Close()
+	fo.f = f
+	return nil
+}
+
+// expired reports whether ID delivered at t is out of the retention
+func (fo *FileOutbox) expired(t time.Time) bool {
+	return t.Before(fo.now().Add(-fo.retention))
+}
+
+// Pending implements OutboxStore
+func (fo *FileOutbox) Pending() ([]OutboxEntry, error) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	return append([]OutboxEntry(nil), fo.pending...), nil
+}
+
+// Close implements OutboxStore
+func (fo *FileOutbox) Close() error {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	return fo.f.Close()
+}
+
+// write appends r as a line and syncs it
+func (fo *FileOutbox) write(r outboxRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if len(b) > maxOutboxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds %d bytes", len(b), maxOutboxRecordSize)
+	}
+	if _, err := fo.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return fo.f.Sync()
+}
@@ -0,0 +1,242 @@
+package slackop
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+func newTestOutbox(t *testing.T, store OutboxStore, s *slacktest.Server) *Outbox {
+	t.Helper()
+	o, err := NewOutbox(context.Background(), store, WebhookSender(),
+		WithWorkers(1), WithRateInterval(0), WithQueueRetry(retry.NewConfig(time.Millisecond, time.Millisecond, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func webhookTexts(s *slacktest.Server) string {
+	var texts []string
+	for _, r := range s.RequestsOf(slacktest.MethodWebhook) {
+		texts = append(texts, r.Payload()["text"].(string))
+	}
+	return strings.Join(texts, ",")
+}
+
+func TestOutbox_Dedup(t *testing.T) {
+	s := newTestWebhook(t)
+	store := NewMemoryOutbox()
+	o := newTestOutbox(t, store, s)
+	ctx := context.Background()
+
+	o.Enqueue(ctx, "tweet-1", s.WebhookURL(), &Message{Text: "first"})
+	o.Enqueue(ctx, "tweet-1", s.WebhookURL(), &Message{Text: "duplicate"})
+	if err := o.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	o.Enqueue(ctx, "tweet-1", s.WebhookURL(), &Message{Text: "delivered duplicate"})
+	o.Enqueue(ctx, "", s.WebhookURL(), &Message{Text: "no id"})
+	if err := o.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhookTexts(s); got != "first,no id" {
+		t.Errorf("got %s, want first,no id", got)
+	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("got pending %v", pending)
+	}
+}
+
+func TestOutbox_ReplayOnStart(t *testing.T) {
+	s := newTestWebhook(t)
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	ctx := context.Background()
+
+	// Instance persisted messages and was killed before sending them
+	store, err := OpenFileOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		store.Add(OutboxEntry{ID: id, Dest: s.WebhookURL(), Msg: &Message{Text: "tweet" + id}, Created: time.Now()})
+	}
+	store.Close()
+
+	store, err = OpenFileOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newTestOutbox(t, store, s)
+	if err := o.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhookTexts(s); got != "tweet1,tweet2" {
+		t.Errorf("got %s, want replayed tweet1,tweet2", got)
+	}
+
+	// Replay of the delivered ID after restart is ignored
+	store, err = OpenFileOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o = newTestOutbox(t, store, s)
+	o.Enqueue(ctx, "2", s.WebhookURL(), &Message{Text: "tweet2"})
+	if err := o.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.RequestsOf(slacktest.MethodWebhook)); n != 2 {
+		t.Errorf("got %d deliveries, want 2", n)
+	}
+}
+
+func TestOutbox_FailedStaysPending(t *testing.T) {
+	s := newTestWebhook(t)
+	s.Inject(slacktest.MethodWebhook, slacktest.SlackError("channel_not_found"))
+	store := NewMemoryOutbox()
+	var failed []string
+	o, err := NewOutbox(context.Background(), store, WebhookSender(), WithRateInterval(0),
+		WithErrorHandler(func(d Delivery, err error) { failed = append(failed, d.ID) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	o.Enqueue(ctx, "1", s.WebhookURL(), &Message{Text: "hello"})
+	o.Flush(ctx)
+	if pending, _ := store.Pending(); len(pending) != 1 || len(failed) != 1 || failed[0] != "1" {
+		t.Fatalf("got pending %v and failed %v", pending, failed)
+	}
+	if n, err := o.Replay(ctx); n != 1 || err != nil {
+		t.Fatalf("got %d %v, want 1 replayed", n, err)
+	}
+	o.Close(ctx)
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("got pending %v after replay", pending)
+	}
+}
+
+func TestFileOutbox_Compaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	lines := []string{
+		`{"op":"add","entry":{"id":"old","dest":"#feed","msg":{"text":"old"}}}`,
+		`{"op":"delivered","id":"old","time":"2020-04-01T09:00:00Z"}`,
+		`{"op":"add","entry":{"id":"recent","dest":"#feed","msg":{"text":"recent"}}}`,
+		`{"op":"delivered","id":"recent","time":"2020-05-01T08:00:00Z"}`,
+		`{"op":"add","entry":{"id":"pending","dest":"#feed","msg":{"blocks":[{"type":"divider"}]}}}`,
+		// broken by crash while writing
+		`{"op":"add","entry":{"id":"bro`,
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fo, err := OpenFileOutbox(path, func(fo *FileOutbox) { fo.now = func() time.Time { return now } })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fo.Close()
+	pending, _ := fo.Pending()
+	if len(pending) != 1 || pending[0].ID != "pending" || len(pending[0].Msg.Blocks) != 1 {
+		t.Fatalf("got pending %+v", pending)
+	}
+	if added, _ := fo.Add(OutboxEntry{ID: "recent"}); added {
+		t.Error("recently delivered ID was added again")
+	}
+	if added, _ := fo.Add(OutboxEntry{ID: "old", Dest: "#feed", Msg: &Message{Text: "old"}}); !added {
+		t.Error("ID delivered before retention was not added")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "bro") || strings.Count(string(b), "\n") != 3 {
+		t.Errorf("got file\n%s", b)
+	}
+}
+
+func TestFileOutbox_DeliveredRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	fo, err := OpenFileOutbox(path, WithDeliveredRetention(time.Hour), func(fo *FileOutbox) { fo.now = func() time.Time { return now } })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fo.Close()
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := fo.Add(OutboxEntry{ID: id, Dest: "#feed", Msg: &Message{Text: id}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := fo.MarkDelivered(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(time.Hour + time.Second)
+	if added, _ := fo.Add(OutboxEntry{ID: "1", Dest: "#feed", Msg: &Message{Text: "1"}}); !added {
+		t.Error("ID delivered before retention was not added")
+	}
+	if err := fo.MarkDelivered("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"2"`) || strings.Count(string(b), "\n") != 1 {
+		t.Errorf("got file\n%s", b)
+	}
+}
+
+func TestFileOutbox_OversizedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	huge := strings.Repeat("x", maxOutboxRecordSize)
+	lines := []string{
+		`{"op":"add","entry":{"id":"huge","dest":"#feed","msg":{"text":"` + huge + `"}}}`,
+		`{"op":"add","entry":{"id":"small","dest":"#feed","msg":{"text":"small"}}}`,
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fo, err := OpenFileOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fo.Close()
+	if pending, _ := fo.Pending(); len(pending) != 1 || pending[0].ID != "small" {
+		t.Fatalf("got pending %+v", pending)
+	}
+	if _, err := fo.Add(OutboxEntry{ID: "huge", Dest: "#feed", Msg: &Message{Text: huge}}); err == nil {
+		t.Error("oversized entry was added")
+	}
+}
+
+func TestMemoryOutbox_DeliveredRetention(t *testing.T) {
+	m := NewMemoryOutbox()
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	m.delivered.now = func() time.Time { return now }
+
+	m.Add(OutboxEntry{ID: "1"})
+	m.MarkDelivered("1")
+	if added, _ := m.Add(OutboxEntry{ID: "1"}); added {
+		t.Error("delivered ID was added again")
+	}
+	now = now.Add(DefaultDeliveredRetention + time.Second)
+	m.MarkDelivered("2")
+	if added, _ := m.Add(OutboxEntry{ID: "1"}); !added {
+		t.Error("ID delivered before retention was not added")
+	}
+	if n := len(m.delivered.keys); n != 1 {
+		t.Errorf("got %d delivered IDs, want %d", n, 1)
+	}
+}
@@ -0,0 +1,412 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+)
+
+// Default setting of Queue
+const (
+	DefaultQueueWorkers    = 2
+	DefaultQueueBufferSize = 100
+	// DefaultRateInterval follows Slack limit of about one message per second per channel
+	DefaultRateInterval = time.Second
+)
+
+// Errors given by Queue
+var (
+	ErrQueueFull   = errors.New("slackop: queue is full")
+	ErrQueueClosed = errors.New("slackop: queue is closed")
+	// ErrDropped is given to the error handler when a delivery was dropped from the buffer
+	ErrDropped = errors.New("slackop: delivery was dropped")
+)
+
+// OverflowPolicy decides what Queue does when its buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Enqueue wait until the buffer has room or ctx is done
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest delivery in the buffer to accept new one
+	OverflowDropOldest
+	// OverflowDropNewest rejects new delivery with ErrQueueFull
+	OverflowDropNewest
+)
+
+// SenderFunc delivers msg to dest such as webhook URL or channel
+type SenderFunc func(ctx context.Context, dest string, msg *Message) error
+
+// WebhookSender gives SenderFunc which posts to incoming webhook URL given as dest.
+// Retry should be configured on Queue rather than by opts.
+func WebhookSender(opts ...Option) SenderFunc {
+	return func(ctx context.Context, dest string, msg *Message) error {
+		return NewManager(dest, opts...).NotifyContext(ctx, msg)
+	}
+}
+
+// ChannelSender gives SenderFunc which posts to channel given as dest by chat.postMessage
+func ChannelSender(c *Client) SenderFunc {
+	return func(ctx context.Context, dest string, msg *Message) error {
+		_, err := c.PostMessage(ctx, dest, msg)
+		return err
+	}
+}
+
+// Delivery is a message waiting in Queue
+type Delivery struct {
+	// ID is dedup ID of the message given to Outbox
+	ID   string
+	Dest string
+	Msg  *Message
+}
+
+// Queue delivers messages asynchronously by worker goroutines.
+// Deliveries to the same destination are spaced by the rate interval, and
+// failures are retried honoring Retry-After of Slack.
+type Queue struct {
+	send     SenderFunc
+	workers  int
+	bufSize  int
+	policy   OverflowPolicy
+	interval time.Duration
+	retry    retry.Config
+	onError  func(d Delivery, err error)
+	// onDelivered is called with successful deliveries such as to mark them in Outbox
+	onDelivered func(d Delivery)
+
+	limiter *rateLimiter
+	ch      chan Delivery
+	// slots has a token per delivery in ch or about to be sent to it,
+	// so that sending to ch holding a token never blocks
+	slots   chan struct{}
+	quit    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	closing sync.RWMutex
+	closed  bool
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+}
+
+// QueueOption configures Queue
+type QueueOption func(*Queue)
+
+// WithWorkers sets number of worker goroutines
+func WithWorkers(n int) QueueOption {
+	return func(q *Queue) {
+		q.workers = n
+	}
+}
+
+// WithBufferSize sets number of deliveries kept in the buffer
+func WithBufferSize(n int) QueueOption {
+	return func(q *Queue) {
+		q.bufSize = n
+	}
+}
+
+// WithOverflowPolicy sets behavior of Enqueue when the buffer is full
+func WithOverflowPolicy(p OverflowPolicy) QueueOption {
+	return func(q *Queue) {
+		q.policy = p
+	}
+}
+
+// WithRateInterval sets minimum interval of deliveries to the same destination
+func WithRateInterval(d time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.interval = d
+	}
+}
+
+// WithQueueRetry sets retry of failed deliveries
+func WithQueueRetry(cfg retry.Config) QueueOption {
+	return func(q *Queue) {
+		q.retry = cfg
+	}
+}
+
+// WithErrorHandler sets f called with deliveries which failed or were dropped
+func WithErrorHandler(f func(d Delivery, err error)) QueueOption {
+	return func(q *Queue) {
+		q.onError = f
+	}
+}
+
+// NewQueue starts workers delivering messages by send
+func NewQueue(send SenderFunc, opts ...QueueOption) *Queue {
+	q := &Queue{
+		send:        send,
+		workers:     DefaultQueueWorkers,
+		bufSize:     DefaultQueueBufferSize,
+		interval:    DefaultRateInterval,
+		retry:       retry.NewConfig(time.Second, 30*time.Second, 2, 5),
+		onError:     func(Delivery, error) {},
+		onDelivered: func(Delivery) {},
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.limiter = newRateLimiter(q.interval)
+	q.ch = make(chan Delivery, q.bufSize)
+	q.slots = make(chan struct{}, q.bufSize)
+	q.quit = make(chan struct{})
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue adds msg to be delivered to dest.
+// When the buffer is full, it behaves as the OverflowPolicy of the queue.
+func (q *Queue) Enqueue(ctx context.Context, dest string, msg *Message) error {
+	return q.enqueue(ctx, Delivery{Dest: dest, Msg: msg})
+}
+
+func (q *Queue) enqueue(ctx context.Context, d Delivery) error {
+	q.closing.RLock()
+	closed := q.closed
+	q.closing.RUnlock()
+	if closed {
+		return ErrQueueClosed
+	}
+
+	switch q.policy {
+	case OverflowDropNewest:
+		select {
+		case q.slots <- struct{}{}:
+		default:
+			return ErrQueueFull
+		}
+	case OverflowDropOldest:
+		select {
+		case q.slots <- struct{}{}:
+		default:
+			select {
+			case q.slots <- struct{}{}:
+			case old, ok := <-q.ch:
+				if !ok {
+					return ErrQueueClosed
+				}
+				// The slot of the oldest is taken over
+				q.onError(old, ErrDropped)
+				q.done()
+			}
+		}
+	default:
+		// The lock is not held while blocking so that Close isn't blocked by full buffer
+		select {
+		case q.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.quit:
+			return ErrQueueClosed
+		}
+	}
+	return q.push(d)
+}
+
+// push sends d to the buffer holding a slot
+func (q *Queue) push(d Delivery) error {
+	q.closing.RLock()
+	defer q.closing.RUnlock()
+	if q.closed {
+		<-q.slots
+		return ErrQueueClosed
+	}
+	q.add(1)
+	q.ch <- d
+	return nil
+}
+
+// Flush waits until every enqueued delivery is finished or ctx is done
+func (q *Queue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	if q.pending == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := q.idle
+	q.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting deliveries and waits until the buffered ones are delivered.
+// When ctx is done before that, in-flight deliveries are cancelled and ctx.Err() is returned.
+func (q *Queue) Close(ctx context.Context) error {
+	q.closing.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.quit)
+		close(q.ch)
+	}
+	q.closing.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+// work delivers messages in the buffer until it's closed
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for d := range q.ch {
+		<-q.slots
+		if q.ctx.Err() != nil {
+			q.onError(d, q.ctx.Err())
+			q.done()
+			continue
+		}
+		if err := q.deliver(d); err != nil {
+			q.onError(d, err)
+		} else {
+			q.onDelivered(d)
+		}
+		q.done()
+	}
+}
+
+// deliver sends d waiting for its rate limit slot on every trial
+func (q *Queue) deliver(d Delivery) error {
+	return retry.RunWithRetry(q.ctx, q.retry, func() error {
+		release, err := q.limiter.wait(q.ctx, d.Dest)
+		if err != nil {
+			return err
+		}
+		defer release()
+		err = q.send(q.ctx, d.Dest, d.Msg)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			q.limiter.delay(d.Dest, apiErr.RetryAfter)
+		}
+		return err
+	}, isRetryable)
+}
+
+func (q *Queue) add(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == 0 {
+		q.idle = make(chan struct{})
+	}
+	q.pending += n
+}
+
+func (q *Queue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		close(q.idle)
+	}
+}
+
+// rateLimiter spaces calls to the same destination by interval.
+// Calls to the same destination take turns, so that the next one is scheduled
+// from the time when the previous one actually started.
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	dests    map[string]*destLimit
+}
+
+// destLimit is state of rateLimiter for a destination
+type destLimit struct {
+	// turn is held from the wait until the call finishes
+	turn chan struct{}
+	next time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval, dests: map[string]*destLimit{}}
+}
+
+func (l *rateLimiter) dest(dest string) *destLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dl, ok := l.dests[dest]
+	if !ok {
+		dl = &destLimit{turn: make(chan struct{}, 1)}
+		l.dests[dest] = dl
+	}
+	return dl
+}
+
+// wait takes the turn of dest and sleeps until its slot comes.
+// release must be called when the call to dest finished.
+// Without interval, calls don't take turns and only wait for delay of Retry-After.
+func (l *rateLimiter) wait(ctx context.Context, dest string) (release func(), err error) {
+	dl := l.dest(dest)
+	if l.interval <= 0 {
+		return func() {}, sleepUntil(ctx, l.next(dl))
+	}
+	select {
+	case dl.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if err := sleepUntil(ctx, l.next(dl)); err != nil {
+		<-dl.turn
+		return nil, err
+	}
+	l.mu.Lock()
+	dl.next = time.Now().Add(l.interval)
+	l.mu.Unlock()
+	return func() { <-dl.turn }, nil
+}
+
+func (l *rateLimiter) next(dl *destLimit) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return dl.next
+}
+
+// sleepUntil sleeps until at or ctx is done
+func sleepUntil(ctx context.Context, at time.Time) error {
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay pushes the next slot of dest back by d from now
+func (l *rateLimiter) delay(dest string, d time.Duration) {
+	dl := l.dest(dest)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at := time.Now().Add(d); at.After(dl.next) {
+		dl.next = at
+	}
+}
@@ -0,0 +1,201 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/retry"
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+// sentLog records deliveries made by a queue
+type sentLog struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+func (l *sentLog) send(ctx context.Context, dest string, msg *Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sent == nil {
+		l.sent = map[string][]time.Time{}
+	}
+	l.sent[dest] = append(l.sent[dest], time.Now())
+	return nil
+}
+
+func (l *sentLog) count(dest string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.sent[dest])
+}
+
+func TestQueue_RateLimit(t *testing.T) {
+	l := &sentLog{}
+	interval := 50 * time.Millisecond
+	q := NewQueue(l.send, WithWorkers(4), WithRateInterval(interval))
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		q.Enqueue(ctx, "#feed", &Message{Text: "feed"})
+		q.Enqueue(ctx, "#oncall", &Message{Text: "oncall"})
+	}
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, dest := range []string{"#feed", "#oncall"} {
+		times := l.sent[dest]
+		if len(times) != 3 {
+			t.Fatalf("%s: got %d deliveries, want %d", dest, len(times), 3)
+		}
+		for i := 1; i < len(times); i++ {
+			// allow jitter of timer
+			if d := times[i].Sub(times[i-1]); d < interval-5*time.Millisecond {
+				t.Errorf("%s: interval got %v, want >= %v", dest, d, interval)
+			}
+		}
+	}
+}
+
+func TestQueue_Overflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		wantErr error
+		want    []string
+	}{
+		{name: "drop newest", policy: OverflowDropNewest, wantErr: ErrQueueFull, want: []string{"first", "second"}},
+		{name: "drop oldest", policy: OverflowDropOldest, want: []string{"first", "third"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var got []string
+			started := make(chan struct{}, 3)
+			send := func(ctx context.Context, dest string, msg *Message) error {
+				started <- struct{}{}
+				<-release
+				mu.Lock()
+				got = append(got, msg.Text)
+				mu.Unlock()
+				return nil
+			}
+			var dropped int
+			q := NewQueue(send, WithWorkers(1), WithBufferSize(1), WithRateInterval(0),
+				WithOverflowPolicy(tt.policy),
+				WithErrorHandler(func(d Delivery, err error) {
+					if errors.Is(err, ErrDropped) {
+						dropped++
+					}
+				}))
+			ctx := context.Background()
+
+			q.Enqueue(ctx, "#feed", &Message{Text: "first"})
+			<-started
+			q.Enqueue(ctx, "#feed", &Message{Text: "second"})
+			err := q.Enqueue(ctx, "#feed", &Message{Text: "third"})
+			if err != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			close(release)
+			if err := q.Flush(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			q.Close(ctx)
+
+			if len(got) != len(tt.want) || got[0] != tt.want[0] || got[1] != tt.want[1] {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if tt.policy == OverflowDropOldest && dropped != 1 {
+				t.Errorf("dropped: got %d, want %d", dropped, 1)
+			}
+		})
+	}
+}
+
+func TestQueue_RetryAfter(t *testing.T) {
+	s := newTestWebhook(t)
+	s.Inject(slacktest.MethodWebhook, slacktest.RateLimited(time.Second))
+
+	var failed error
+	q := NewQueue(WebhookSender(),
+		WithQueueRetry(retry.NewConfig(time.Millisecond, 10*time.Millisecond, 2, 3)),
+		WithErrorHandler(func(d Delivery, err error) { failed = err }))
+	ctx := context.Background()
+	q.Enqueue(ctx, s.WebhookURL(), &Message{Text: "hello"})
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if failed != nil {
+		t.Fatalf("unexpected error: %v", failed)
+	}
+	reqs := s.RequestsOf(slacktest.MethodWebhook)
+	if len(reqs) != 2 {
+		t.Fatalf("calls: got %d, want %d", len(reqs), 2)
+	}
+	if d := reqs[1].Time.Sub(reqs[0].Time); d < time.Second {
+		t.Errorf("retried after %v, want >= %v", d, time.Second)
+	}
+}
+
+func TestQueue_Close(t *testing.T) {
+	l := &sentLog{}
+	q := NewQueue(l.send, WithRateInterval(0))
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if err := q.Enqueue(ctx, "#feed", &Message{Text: "hello"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := l.count("#feed"); n != 10 {
+		t.Errorf("delivered: got %d, want %d", n, 10)
+	}
+	if err := q.Enqueue(ctx, "#feed", &Message{Text: "late"}); err != ErrQueueClosed {
+		t.Errorf("got %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestQueue_CloseWhileBlocked(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	q := NewQueue(func(ctx context.Context, dest string, msg *Message) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, WithWorkers(1), WithBufferSize(1), WithRateInterval(0))
+	ctx := context.Background()
+	q.Enqueue(ctx, "#feed", &Message{Text: "first"})
+	<-started
+	q.Enqueue(ctx, "#feed", &Message{Text: "second"})
+
+	// The buffer is full and Enqueue blocks
+	blocked := make(chan error)
+	go func() { blocked <- q.Enqueue(ctx, "#feed", &Message{Text: "third"}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	closed := make(chan error)
+	go func() { closed <- q.Close(closeCtx) }()
+	select {
+	case err := <-blocked:
+		if err != ErrQueueClosed {
+			t.Errorf("got %v, want %v", err, ErrQueueClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue is not released by Close")
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close deadlocked behind blocked Enqueue")
+	}
+}
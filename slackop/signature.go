@@ -0,0 +1,99 @@
+package slackop
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of Slack request signing.
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+const (
+	HeaderSignature = "X-Slack-Signature"
+	HeaderTimestamp = "X-Slack-Request-Timestamp"
+)
+
+// DefaultReplayWindow is allowed age of signed request
+const DefaultReplayWindow = 5 * time.Minute
+
+// signatureVersion is prefix of the signature
+const signatureVersion = "v0"
+
+// maxRequestBodySize is maximum size of request body from Slack
+const maxRequestBodySize = 1 << 20
+
+// Errors of request verification
+var (
+	ErrMissingSignature = errors.New("slackop: missing signature headers")
+	ErrInvalidSignature = errors.New("slackop: invalid signature")
+	ErrExpiredTimestamp = errors.New("slackop: request timestamp is out of replay window")
+)
+
+// ErrEmptySigningSecret is returned when Handler or EventsHandler is created without signing secret
+var ErrEmptySigningSecret = errors.New("slackop: signing secret is empty")
+
+// Verifier verifies requests from Slack signed by signing secret
+type Verifier struct {
+	secret []byte
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewVerifier gives Verifier of signingSecret with DefaultReplayWindow
+func NewVerifier(signingSecret string) *Verifier {
+	return &Verifier{
+		secret: []byte(signingSecret),
+		window: DefaultReplayWindow,
+		now:    time.Now,
+	}
+}
+
+// Verify checks signature and timestamp in header for body
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	sig := header.Get(HeaderSignature)
+	ts := header.Get(HeaderTimestamp)
+	if sig == "" || ts == "" {
+		return ErrMissingSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := v.now().Sub(time.Unix(sec, 0))
+	if age > v.window || age < -v.window {
+		return ErrExpiredTimestamp
+	}
+	if !hmac.Equal([]byte(sig), []byte(Sign(v.secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyRequest reads and verifies body of r.
+// Body of r is replaced so that it can be read again.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := v.Verify(r.Header, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Sign gives X-Slack-Signature of body sent at timestamp ts
+func Sign(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signatureVersion + ":" + ts + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
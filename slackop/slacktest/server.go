@@ -0,0 +1,611 @@
+// Package slacktest provides in-process fake of Slack incoming webhooks and Web API for tests.
+//
+//	s := slacktest.NewServer()
+//	defer s.Close()
+//	mgr := slackop.NewManager(s.WebhookURL())
+//	s.Inject(slacktest.MethodWebhook, slacktest.RateLimited(time.Second))
+//
+// Every request is recorded and can be inspected by Requests.
+package slacktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultToken is bot token accepted by Server
+const DefaultToken = "xoxb-test"
+
+// Names of endpoints which are not Web API methods, used by Inject and RequestsOf
+const (
+	MethodWebhook     = "webhook"
+	MethodResponseURL = "response_url"
+	MethodUpload      = "upload"
+)
+
+// Paths of the endpoints
+const (
+	apiPath         = "/api/"
+	webhookPath     = "/services/T00000000/B00000000/XXXXXXXXXXXXXXXXXXXXXXXX"
+	responseURLPath = "/actions/T00000000/1234567890/XXXXXXXXXXXXXXXXXXXXXXXX"
+	uploadPath      = "/upload/"
+)
+
+// webhookStatus is status of incoming webhook by error code
+var webhookStatus = map[string]int{
+	"invalid_payload":                   http.StatusBadRequest,
+	"no_text":                           http.StatusBadRequest,
+	"invalid_token":                     http.StatusForbidden,
+	"action_prohibited":                 http.StatusForbidden,
+	"posting_to_general_channel_denied": http.StatusForbidden,
+	"no_service":                        http.StatusNotFound,
+	"channel_not_found":                 http.StatusNotFound,
+	"user_not_found":                    http.StatusNotFound,
+	"channel_is_archived":               http.StatusGone,
+}
+
+// Request is request received by Server
+type Request struct {
+	// Method is Web API method such as "chat.postMessage", or MethodWebhook, MethodResponseURL or MethodUpload
+	Method string
+	Header http.Header
+	Query  url.Values
+	Body   []byte
+	Time   time.Time
+}
+
+// Decode decodes JSON body into v
+func (r Request) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Payload gives JSON body as map
+func (r Request) Payload() map[string]interface{} {
+	m := map[string]interface{}{}
+	json.Unmarshal(r.Body, &m)
+	return m
+}
+
+// Fault is failure answered instead of the normal response
+type Fault struct {
+	// Status is HTTP status. Zero means 200 for Web API and status of the code for webhook.
+	Status     int
+	Code       string
+	RetryAfter time.Duration
+}
+
+// RateLimited answers 429 with Retry-After
+func RateLimited(retryAfter time.Duration) Fault {
+	return Fault{Status: http.StatusTooManyRequests, Code: "ratelimited", RetryAfter: retryAfter}
+}
+
+// ServerError answers status such as 503 without Slack error code
+func ServerError(status int) Fault {
+	return Fault{Status: status}
+}
+
+// SlackError answers error code such as "channel_not_found"
+func SlackError(code string) Fault {
+	return Fault{Code: code}
+}
+
+// Conversation is channel listed by conversations.list and accepted by chat.postMessage
+type Conversation struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IsChannel  bool   `json:"is_channel"`
+	IsPrivate  bool   `json:"is_private"`
+	IsArchived bool   `json:"is_archived"`
+	IsMember   bool   `json:"is_member"`
+}
+
+// Server is fake Slack
+type Server struct {
+	// URL is base URL of the server such as "http://127.0.0.1:1234"
+	URL string
+
+	srv   *httptest.Server
+	token string
+
+	mu            sync.Mutex
+	requests      []Request
+	received      chan struct{}
+	faults        map[string][]Fault
+	conversations []Conversation
+	messages      map[string]bool
+	seq           int
+}
+
+// Option configures Server
+type Option func(*Server)
+
+// WithToken sets bot token accepted by Web API instead of DefaultToken
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithConversations sets channels of the workspace.
+// chat.postMessage answers channel_not_found for other channels when it's given.
+func WithConversations(convs ...Conversation) Option {
+	return func(s *Server) {
+		s.conversations = convs
+	}
+}
+
+// NewServer starts Server
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		token:    DefaultToken,
+		received: make(chan struct{}),
+		faults:   map[string][]Fault{},
+		messages: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// WebhookURL gives URL of incoming webhook
+func (s *Server) WebhookURL() string {
+	return s.URL + webhookPath
+}
+
+// APIURL gives base URL of Web API for slackop.WithAPIURL
+func (s *Server) APIURL() string {
+	return s.URL + apiPath
+}
+
+// ResponseURL gives response_url of slash commands and interactions
+func (s *Server) ResponseURL() string {
+	return s.URL + responseURLPath
+}
+
+// Inject makes the next requests of method answer faults in order.
+// Method is Web API method such as "chat.postMessage", or MethodWebhook, MethodResponseURL or MethodUpload.
+func (s *Server) Inject(method string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = append(s.faults[method], faults...)
+}
+
+// Requests gives all received requests in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsOf gives received requests of method in order
+func (s *Server) RequestsOf(method string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reqs []Request
+	for _, r := range s.requests {
+		if r.Method == method {
+			reqs = append(reqs, r)
+		}
+	}
+	return reqs
+}
+
+// WaitRequests waits until n requests of method are received, for asynchronous senders.
+// It gives the received requests which can be fewer than n on timeout.
+func (s *Server) WaitRequests(method string, n int, timeout time.Duration) []Request {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		received := s.received
+		s.mu.Unlock()
+		if reqs := s.RequestsOf(method); len(reqs) >= n {
+			return reqs
+		}
+		select {
+		case <-received:
+		case <-deadline.C:
+			return s.RequestsOf(method)
+		}
+	}
+}
+
+// Reset forgets requests, faults and posted messages
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.faults = map[string][]Fault{}
+	s.messages = map[string]bool{}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var method string
+	switch {
+	case strings.HasPrefix(r.URL.Path, apiPath):
+		method = strings.TrimPrefix(r.URL.Path, apiPath)
+	case r.URL.Path == webhookPath:
+		method = MethodWebhook
+	case r.URL.Path == responseURLPath:
+		method = MethodResponseURL
+	case strings.HasPrefix(r.URL.Path, uploadPath):
+		method = MethodUpload
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := Request{Method: method, Header: r.Header.Clone(), Query: r.URL.Query(), Body: body, Time: time.Now()}
+	fault, faulty := s.record(req)
+
+	switch method {
+	case MethodWebhook, MethodResponseURL:
+		if faulty {
+			writeWebhookFault(w, fault)
+			return
+		}
+		s.serveWebhook(w, req)
+	case MethodUpload:
+		if faulty {
+			writeWebhookFault(w, fault)
+			return
+		}
+		fmt.Fprintf(w, "OK - %d", len(body))
+	default:
+		if faulty {
+			writeAPIFault(w, fault)
+			return
+		}
+		s.serveAPI(w, req)
+	}
+}
+
+// record saves req and pops fault injected for it
+func (s *Server) record(req Request) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	close(s.received)
+	s.received = make(chan struct{})
+	faults := s.faults[req.Method]
+	if len(faults) == 0 {
+		return Fault{}, false
+	}
+	s.faults[req.Method] = faults[1:]
+	return faults[0], true
+}
+
+func (s *Server) serveWebhook(w http.ResponseWriter, req Request) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(req.Body, &msg); err != nil {
+		writeWebhookFault(w, SlackError("invalid_payload"))
+		return
+	}
+	if !hasContent(msg) && req.Method == MethodWebhook {
+		writeWebhookFault(w, SlackError("no_text"))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, req Request) {
+	auth := req.Header.Get("Authorization")
+	switch {
+	case auth == "":
+		writeAPIFault(w, SlackError("not_authed"))
+		return
+	case auth != "Bearer "+s.token:
+		writeAPIFault(w, SlackError("invalid_auth"))
+		return
+	}
+
+	var res map[string]interface{}
+	var code string
+	switch req.Method {
+	case "chat.postMessage":
+		res, code = s.postMessage(req)
+	case "chat.update":
+		res, code = s.updateMessage(req)
+	case "chat.delete":
+		res, code = s.deleteMessage(req)
+	case "chat.postEphemeral":
+		res, code = s.postEphemeral(req)
+	case "conversations.list":
+		res, code = s.listConversations(req)
+	case "files.upload":
+		res, code = s.uploadFile(req)
+	case "files.getUploadURLExternal":
+		res, code = s.getUploadURL(req)
+	case "files.completeUploadExternal":
+		res, code = s.completeUpload(req)
+	default:
+		code = "unknown_method"
+	}
+	if code != "" {
+		writeAPIFault(w, SlackError(code))
+		return
+	}
+	res["ok"] = true
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) postMessage(req Request) (map[string]interface{}, string) {
+	var msg map[string]interface{}
+	if err := req.Decode(&msg); err != nil {
+		return nil, "invalid_json"
+	}
+	channel, _ := msg["channel"].(string)
+	if channel == "" {
+		return nil, "channel_not_found"
+	}
+	if !hasContent(msg) {
+		return nil, "no_text"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, code := s.channelID(channel)
+	if code != "" {
+		return nil, code
+	}
+	ts := s.nextTS()
+	s.messages[id+"/"+ts] = true
+	msg["ts"] = ts
+	delete(msg, "channel")
+	return map[string]interface{}{"channel": id, "ts": ts, "message": msg}, ""
+}
+
+func (s *Server) updateMessage(req Request) (map[string]interface{}, string) {
+	var msg map[string]interface{}
+	if err := req.Decode(&msg); err != nil {
+		return nil, "invalid_json"
+	}
+	channel, _ := msg["channel"].(string)
+	ts, _ := msg["ts"].(string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, code := s.channelID(channel)
+	if code != "" {
+		return nil, code
+	}
+	if !s.messages[id+"/"+ts] {
+		return nil, "message_not_found"
+	}
+	return map[string]interface{}{"channel": id, "ts": ts, "text": msg["text"]}, ""
+}
+
+func (s *Server) deleteMessage(req Request) (map[string]interface{}, string) {
+	var msg map[string]string
+	if err := req.Decode(&msg); err != nil {
+		return nil, "invalid_json"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, code := s.channelID(msg["channel"])
+	if code != "" {
+		return nil, code
+	}
+	key := id + "/" + msg["ts"]
+	if !s.messages[key] {
+		return nil, "message_not_found"
+	}
+	delete(s.messages, key)
+	return map[string]interface{}{"channel": id, "ts": msg["ts"]}, ""
+}
+
+func (s *Server) postEphemeral(req Request) (map[string]interface{}, string) {
+	var msg map[string]interface{}
+	if err := req.Decode(&msg); err != nil {
+		return nil, "invalid_json"
+	}
+	if user, _ := msg["user"].(string); user == "" {
+		return nil, "user_not_in_channel"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channel, _ := msg["channel"].(string)
+	if _, code := s.channelID(channel); code != "" {
+		return nil, code
+	}
+	return map[string]interface{}{"message_ts": s.nextTS()}, ""
+}
+
+func (s *Server) listConversations(req Request) (map[string]interface{}, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var convs []Conversation
+	for _, c := range s.conversations {
+		if req.Query.Get("exclude_archived") == "true" && c.IsArchived {
+			continue
+		}
+		convs = append(convs, c)
+	}
+	start, _ := strconv.Atoi(req.Query.Get("cursor"))
+	if start > len(convs) {
+		return nil, "invalid_cursor"
+	}
+	end := len(convs)
+	if limit, _ := strconv.Atoi(req.Query.Get("limit")); limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	next := ""
+	if end < len(convs) {
+		next = strconv.Itoa(end)
+	}
+	channels := append([]Conversation{}, convs[start:end]...)
+	return map[string]interface{}{
+		"channels":          channels,
+		"response_metadata": map[string]string{"next_cursor": next},
+	}, ""
+}
+
+func (s *Server) uploadFile(req Request) (map[string]interface{}, string) {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, "invalid_form_data"
+	}
+	form, err := multipart.NewReader(bytes.NewReader(req.Body), params["boundary"]).ReadForm(int64(len(req.Body)) + 1)
+	if err != nil {
+		return nil, "invalid_form_data"
+	}
+	defer form.RemoveAll()
+	files := form.File["file"]
+	if len(files) == 0 {
+		return nil, "no_file_data"
+	}
+	s.mu.Lock()
+	id := s.nextFileID()
+	s.mu.Unlock()
+	return map[string]interface{}{"file": map[string]interface{}{
+		"id":       id,
+		"name":     formValue(form, "filename"),
+		"title":    formValue(form, "title"),
+		"filetype": formValue(form, "filetype"),
+		"size":     files[0].Size,
+	}}, ""
+}
+
+func (s *Server) getUploadURL(req Request) (map[string]interface{}, string) {
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil || form.Get("filename") == "" || form.Get("length") == "" {
+		return nil, "invalid_arguments"
+	}
+	s.mu.Lock()
+	id := s.nextFileID()
+	s.mu.Unlock()
+	return map[string]interface{}{"upload_url": s.URL + uploadPath + id, "file_id": id}, ""
+}
+
+func (s *Server) completeUpload(req Request) (map[string]interface{}, string) {
+	var body struct {
+		Files []map[string]string `json:"files"`
+	}
+	if err := req.Decode(&body); err != nil || len(body.Files) == 0 {
+		return nil, "invalid_arguments"
+	}
+	return map[string]interface{}{"files": body.Files}, ""
+}
+
+// channelID resolves channel given as ID, name or "#name"
+func (s *Server) channelID(channel string) (string, string) {
+	if len(s.conversations) == 0 {
+		return channel, ""
+	}
+	name := strings.TrimPrefix(channel, "#")
+	for _, c := range s.conversations {
+		if c.ID == channel || c.Name == name {
+			if c.IsArchived {
+				return "", "is_archived"
+			}
+			return c.ID, ""
+		}
+	}
+	return "", "channel_not_found"
+}
+
+// nextTS gives unique message timestamp
+func (s *Server) nextTS() string {
+	s.seq++
+	return fmt.Sprintf("1600000000.%06d", s.seq)
+}
+
+// nextFileID gives unique file ID
+func (s *Server) nextFileID() string {
+	s.seq++
+	return fmt.Sprintf("F%08d", s.seq)
+}
+
+// hasContent reports whether msg has text, blocks or attachments
+func hasContent(msg map[string]interface{}) bool {
+	for _, k := range []string{"text", "blocks", "attachments"} {
+		switch v := msg[k].(type) {
+		case string:
+			if v != "" {
+				return true
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formValue(form *multipart.Form, key string) string {
+	if vs := form.Value[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func writeAPIFault(w http.ResponseWriter, f Fault) {
+	setRetryAfter(w, f)
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if f.Code == "" {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	writeJSON(w, status, map[string]interface{}{"ok": false, "error": f.Code})
+}
+
+func writeWebhookFault(w http.ResponseWriter, f Fault) {
+	setRetryAfter(w, f)
+	status := f.Status
+	if status == 0 {
+		status = webhookStatus[f.Code]
+	}
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	body := f.Code
+	if body == "" {
+		body = http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func setRetryAfter(w http.ResponseWriter, f Fault) {
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.RetryAfter.Seconds()))))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
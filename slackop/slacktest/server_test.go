@@ -0,0 +1,115 @@
+package slacktest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return buf.String()
+}
+
+func TestServer_Webhook(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.Inject(MethodWebhook, RateLimited(1500*time.Millisecond), SlackError("channel_not_found"))
+	tests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{body: `{"text":"hello"}`, status: http.StatusTooManyRequests, want: "ratelimited"},
+		{body: `{"text":"hello"}`, status: http.StatusNotFound, want: "channel_not_found"},
+		{body: `{"text":"hello"}`, status: http.StatusOK, want: "ok"},
+		{body: `{}`, status: http.StatusBadRequest, want: "no_text"},
+		{body: `{`, status: http.StatusBadRequest, want: "invalid_payload"},
+	}
+	for _, tt := range tests {
+		resp := post(t, s.WebhookURL(), "", tt.body)
+		if got := readBody(t, resp); resp.StatusCode != tt.status || got != tt.want {
+			t.Errorf("%s: got %d %s, want %d %s", tt.body, resp.StatusCode, got, tt.status, tt.want)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != "2" {
+			t.Errorf("got Retry-After %q, want 2", resp.Header.Get("Retry-After"))
+		}
+	}
+	if n := len(s.RequestsOf(MethodWebhook)); n != len(tests) {
+		t.Errorf("got %d requests, want %d", n, len(tests))
+	}
+	s.Reset()
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("got %d requests after reset", n)
+	}
+}
+
+func TestServer_API(t *testing.T) {
+	s := NewServer(WithConversations(Conversation{ID: "C1", Name: "feed"}, Conversation{ID: "C2", Name: "old", IsArchived: true}))
+	defer s.Close()
+
+	tests := []struct {
+		token string
+		body  string
+		want  string
+	}{
+		{token: "", body: `{"channel":"C1","text":"hello"}`, want: `"error":"not_authed"`},
+		{token: "xoxb-wrong", body: `{"channel":"C1","text":"hello"}`, want: `"error":"invalid_auth"`},
+		{token: DefaultToken, body: `{"channel":"#nowhere","text":"hello"}`, want: `"error":"channel_not_found"`},
+		{token: DefaultToken, body: `{"channel":"#old","text":"hello"}`, want: `"error":"is_archived"`},
+		{token: DefaultToken, body: `{"channel":"#feed"}`, want: `"error":"no_text"`},
+		{token: DefaultToken, body: `{"channel":"#feed","text":"hello"}`, want: `"channel":"C1"`},
+	}
+	for _, tt := range tests {
+		got := readBody(t, post(t, s.APIURL()+"chat.postMessage", tt.token, tt.body))
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.body, got, tt.want)
+		}
+	}
+	if got := readBody(t, post(t, s.APIURL()+"users.list", DefaultToken, `{}`)); !strings.Contains(got, "unknown_method") {
+		t.Errorf("got %s, want unknown_method", got)
+	}
+}
+
+func TestServer_WaitRequests(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Post(s.ResponseURL(), "application/json", strings.NewReader(`{"text":"done"}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	reqs := s.WaitRequests(MethodResponseURL, 1, time.Second)
+	if len(reqs) != 1 || reqs[0].Payload()["text"] != "done" {
+		t.Errorf("got %v", reqs)
+	}
+	if reqs := s.WaitRequests(MethodResponseURL, 2, 10*time.Millisecond); len(reqs) != 1 {
+		t.Errorf("got %d requests on timeout, want 1", len(reqs))
+	}
+}
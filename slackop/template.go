@@ -0,0 +1,182 @@
+package slackop
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// templateExt is extension of template files loaded by LoadTemplates
+const templateExt = ".tmpl"
+
+// jsonTemplateSuffix marks template rendering Block Kit JSON such as "tweet.json.tmpl"
+const jsonTemplateSuffix = ".json"
+
+// Templates renders Message by text/template.
+// Template named with ".json" such as "tweet.json" renders JSON of Message including Block Kit,
+// and the others render text of Message.
+type Templates struct {
+	root *template.Template
+}
+
+// NewTemplates gives empty Templates having TemplateFuncs
+func NewTemplates() *Templates {
+	return &Templates{
+		root: template.New("").Funcs(TemplateFuncs()).Option("missingkey=error"),
+	}
+}
+
+// LoadTemplates parses "*.tmpl" files matched with patterns such as "templates/*.tmpl".
+// Template is named by the file name without ".tmpl". Parse error is returned
+// so that broken template is reported on startup.
+func LoadTemplates(patterns ...string) (*Templates, error) {
+	ts := NewTemplates()
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !strings.HasSuffix(f, templateExt) {
+				continue
+			}
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(filepath.Base(f), templateExt)
+			if err := ts.Parse(name, string(b)); err != nil {
+				return nil, fmt.Errorf("%s: %v", f, err)
+			}
+		}
+	}
+	return ts, nil
+}
+
+// Parse adds template of name
+func (ts *Templates) Parse(name, text string) error {
+	if _, err := ts.root.New(name).Parse(text); err != nil {
+		return fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return nil
+}
+
+// Render executes template of name with data and gives validated Message
+func (ts *Templates) Render(name string, data interface{}) (*Message, error) {
+	t := ts.root.Lookup(name)
+	if t == nil {
+		return nil, fmt.Errorf("template %s is not defined", name)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %v", name, err)
+	}
+	msg := &Message{Text: buf.String()}
+	if strings.HasSuffix(name, jsonTemplateSuffix) {
+		msg = &Message{}
+		if err := json.Unmarshal(buf.Bytes(), msg); err != nil {
+			return nil, fmt.Errorf("template %s rendered invalid message: %v", name, err)
+		}
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// TemplateFuncs gives functions available in the templates.
+//
+//	escape     escapes &, < and > for Slack
+//	link       makes <url|text> link
+//	mention    makes <@user> mention
+//	channel    makes <#channel> link
+//	truncate   shortens text to n characters with "…"
+//	formatTime formats time.Time or Twitter/RFC3339 time string by layout
+//	json       encodes value as JSON literal for ".json" templates
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"escape":     Escape,
+		"link":       Link,
+		"mention":    Mention,
+		"channel":    ChannelLink,
+		"truncate":   Truncate,
+		"formatTime": formatTime,
+		"json":       toJSON,
+	}
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Escape escapes control characters of Slack in text
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+var urlEscaper = strings.NewReplacer("|", "%7C", "<", "%3C", ">", "%3E")
+
+// Link gives link to url labeled by text
+func Link(url, text string) string {
+	if text == "" {
+		return "<" + urlEscaper.Replace(url) + ">"
+	}
+	return "<" + urlEscaper.Replace(url) + "|" + Escape(text) + ">"
+}
+
+// Mention gives mention of user ID such as "U012AB3CD"
+func Mention(user string) string {
+	return "<@" + user + ">"
+}
+
+// ChannelLink gives link to channel ID such as "C123ABC456"
+func ChannelLink(channel string) string {
+	return "<#" + channel + ">"
+}
+
+// Truncate shortens text to n characters including trailing "…"
+func Truncate(n int, text string) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(text)
+	return string(r[:n-1]) + "…"
+}
+
+// timeLayouts are layouts tried to parse time given as string.
+// time.RubyDate is the format of created_at in Twitter API.
+var timeLayouts = []string{time.RubyDate, time.RFC3339, "2006-01-02_15:04:05_MST"}
+
+// formatTime formats v given as time.Time or string in timeLayouts
+func formatTime(layout string, v interface{}) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		return t.Format(layout), nil
+	case string:
+		for _, l := range timeLayouts {
+			if parsed, err := time.Parse(l, t); err == nil {
+				return parsed.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("unknown time format %q", t)
+	}
+	return "", fmt.Errorf("unsupported time %T", v)
+}
+
+// toJSON encodes v as JSON literal
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
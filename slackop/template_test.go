@@ -0,0 +1,118 @@
+package slackop
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tweet has the fields of twiop.SimpleTweet used in testdata/templates
+type tweet struct {
+	CreatedAt    string
+	Text         string
+	IDStr        string
+	ScreenName   string
+	RetweetCount int
+}
+
+var testTweet = tweet{
+	CreatedAt:    "Mon Aug 26 09:30:00 +0000 2019",
+	Text:         "Cloud Run <3 & Cloud Pub/Sub is great",
+	IDStr:        "1165",
+	ScreenName:   "gopher",
+	RetweetCount: 12,
+}
+
+func TestTemplates_Render(t *testing.T) {
+	ts, err := LoadTemplates(filepath.Join("testdata", "templates", "*.tmpl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg, err := ts.Render("tweet", testTweet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "New tweet by gopher at 2019-08-26 09:30\n" +
+		"> Cloud Run &lt;3 &amp; Clou…\n" +
+		"<https://twitter.com/gopher/status/1165|Open>\n"
+	if msg.Text != want {
+		t.Errorf("got %q, want %q", msg.Text, want)
+	}
+
+	msg, err = ts.Render("tweet.json", testTweet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Text != "New tweet by gopher" || len(msg.Blocks) != 2 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	section, ok := msg.Blocks[0].(*SectionBlock)
+	if !ok {
+		t.Fatalf("got %T, want *SectionBlock", msg.Blocks[0])
+	}
+	if section.Text.Text != "Cloud Run &lt;3 &amp; Cloud Pub/Sub is great" {
+		t.Errorf("unexpected text: %q", section.Text.Text)
+	}
+	if b, ok := section.Accessory.(*ButtonElement); !ok || b.URL != "https://twitter.com/gopher/status/1165" {
+		t.Errorf("unexpected accessory: %+v", section.Accessory)
+	}
+}
+
+func TestTemplates_Errors(t *testing.T) {
+	_, err := LoadTemplates(filepath.Join("testdata", "broken", "*.tmpl"))
+	if err == nil || !strings.Contains(err.Error(), "broken.tmpl") {
+		t.Errorf("got %v, want parse error of broken.tmpl", err)
+	}
+
+	ts := NewTemplates()
+	if err := ts.Parse("missing", "{{.Nothing}}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ts.Render("missing", map[string]string{}); err == nil {
+		t.Error("got nil, want error of missing key")
+	}
+	if _, err := ts.Render("undefined", nil); err == nil {
+		t.Error("got nil, want error of undefined template")
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{Escape("a < b && c > d"), "a &lt; b &amp;&amp; c &gt; d"},
+		{Link("https://example.com/?q=a|b", "<docs>"), "<https://example.com/?q=a%7Cb|&lt;docs&gt;>"},
+		{Link("https://example.com", ""), "<https://example.com>"},
+		{Mention("U012AB3CD"), "<@U012AB3CD>"},
+		{ChannelLink("C123"), "<#C123>"},
+		{Truncate(5, "こんにちは世界"), "こんにち…"},
+		{Truncate(10, "short"), "short"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestMessage_UnmarshalJSON(t *testing.T) {
+	for _, name := range []string{"text", "blocks", "attachments"} {
+		path := filepath.Join("testdata", "message_"+name+".golden.json")
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(b, msg); err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if err := msg.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		}
+		assertGolden(t, "message_"+name, msg)
+	}
+}
@@ -0,0 +1,294 @@
+package slackop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ThreadRef is parent message of a thread remembered by ThreadStore
+type ThreadRef struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	// Replies is number of replies posted by Threader
+	Replies int `json:"replies"`
+	// Parent is the message posted first, used to update the parent
+	Parent  *Message  `json:"parent,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// ThreadStore remembers thread per correlation key such as keyword or tweet ID
+type ThreadStore interface {
+	// Get gives thread of key. It reports false when key is unknown.
+	Get(key string) (ThreadRef, bool, error)
+	Put(key string, ref ThreadRef) error
+	Delete(key string) error
+}
+
+// ParentUpdater makes new parent message from the first one and number of replies
+// such as "Cloud Run (12 tweets)"
+type ParentUpdater func(parent *Message, replies int) *Message
+
+// Threader posts related messages into a thread.
+// The first message of a key starts the thread and the following ones are posted as its replies.
+type Threader struct {
+	client  *Client
+	channel string
+	store   ThreadStore
+	update  ParentUpdater
+	ttl     time.Duration
+	onError func(key string, err error)
+	now     func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock serializes posts of the same key
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// ThreadOption configures Threader
+type ThreadOption func(*Threader)
+
+// WithParentUpdate updates the parent message by f on every reply
+func WithParentUpdate(f ParentUpdater) ThreadOption {
+	return func(t *Threader) {
+		t.update = f
+	}
+}
+
+// WithThreadTTL starts new thread when the thread of the key is older than d
+func WithThreadTTL(d time.Duration) ThreadOption {
+	return func(t *Threader) {
+		t.ttl = d
+	}
+}
+
+// WithThreadErrorHandler sets f called with errors of updating parent message
+func WithThreadErrorHandler(f func(key string, err error)) ThreadOption {
+	return func(t *Threader) {
+		t.onError = f
+	}
+}
+
+// NewThreader gives Threader posting to channel by c
+func NewThreader(c *Client, channel string, store ThreadStore, opts ...ThreadOption) *Threader {
+	t := &Threader{
+		client:  c,
+		channel: channel,
+		store:   store,
+		onError: func(string, error) {},
+		now:     time.Now,
+		locks:   map[string]*keyLock{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Post posts msg as the parent when key has no thread, otherwise as a reply in the thread
+func (t *Threader) Post(ctx context.Context, key string, msg *Message) (*PostMessageResponse, error) {
+	unlock := t.lock(key)
+	defer unlock()
+
+	ref, ok, err := t.store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread %s: %v", key, err)
+	}
+	if !ok || (t.ttl > 0 && t.now().Sub(ref.Created) > t.ttl) {
+		res, err := t.client.PostMessage(ctx, t.channel, msg)
+		if err != nil {
+			return nil, err
+		}
+		ref = ThreadRef{Channel: res.Channel, TS: res.TS, Created: t.now()}
+		if t.update != nil {
+			ref.Parent = msg
+		}
+		if err := t.store.Put(key, ref); err != nil {
+			return res, fmt.Errorf("failed to save thread %s: %v", key, err)
+		}
+		return res, nil
+	}
+
+	reply := *msg
+	reply.ThreadTS = ref.TS
+	res, err := t.client.PostMessage(ctx, ref.Channel, &reply)
+	if err != nil {
+		return nil, err
+	}
+	ref.Replies++
+	if err := t.store.Put(key, ref); err != nil {
+		return res, fmt.Errorf("failed to save thread %s: %v", key, err)
+	}
+	if t.update != nil && ref.Parent != nil {
+		if _, err := t.client.UpdateMessage(ctx, ref.Channel, ref.TS, t.update(ref.Parent, ref.Replies)); err != nil {
+			t.onError(key, fmt.Errorf("failed to update parent of thread %s: %v", key, err))
+		}
+	}
+	return res, nil
+}
+
+// End forgets thread of key so that the next message starts new thread
+func (t *Threader) End(key string) error {
+	unlock := t.lock(key)
+	defer unlock()
+	return t.store.Delete(key)
+}
+
+// lock locks key and gives function to unlock it
+func (t *Threader) lock(key string) func() {
+	t.mu.Lock()
+	l := t.locks[key]
+	if l == nil {
+		l = &keyLock{}
+		t.locks[key] = l
+	}
+	l.refs++
+	t.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(t.locks, key)
+		}
+	}
+}
+
+// MemoryThreadStore is ThreadStore in memory
+type MemoryThreadStore struct {
+	mu      sync.Mutex
+	threads map[string]ThreadRef
+}
+
+// NewMemoryThreadStore gives empty MemoryThreadStore
+func NewMemoryThreadStore() *MemoryThreadStore {
+	return &MemoryThreadStore{threads: map[string]ThreadRef{}}
+}
+
+// Get implements ThreadStore
+func (m *MemoryThreadStore) Get(key string) (ThreadRef, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ref, ok := m.threads[key]
+	return ref, ok, nil
+}
+
+// Put implements ThreadStore
+func (m *MemoryThreadStore) Put(key string, ref ThreadRef) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.threads[key] = ref
+	return nil
+}
+
+// Delete implements ThreadStore
+func (m *MemoryThreadStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.threads, key)
+	return nil
+}
+
+// FileThreadStore is ThreadStore saved as JSON file.
+// The file is replaced atomically on every change so that threads survive restart.
+type FileThreadStore struct {
+	path string
+
+	mu      sync.Mutex
+	threads map[string]ThreadRef
+}
+
+// OpenFileThreadStore loads threads from path. Missing file is treated as empty.
+func OpenFileThreadStore(path string) (*FileThreadStore, error) {
+	fs := &FileThreadStore{path: path, threads: map[string]ThreadRef{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fs.threads); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return fs, nil
+}
+
+// Get implements ThreadStore
+func (fs *FileThreadStore) Get(key string) (ThreadRef, bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ref, ok := fs.threads[key]
+	return ref, ok, nil
+}
+
+// Put implements ThreadStore
+func (fs *FileThreadStore) Put(key string, ref ThreadRef) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	prev, existed := fs.threads[key]
+	fs.threads[key] = ref
+	if err := fs.save(); err != nil {
+		if existed {
+			fs.threads[key] = prev
+		} else {
+			delete(fs.threads, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete implements ThreadStore
+func (fs *FileThreadStore) Delete(key string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	prev, existed := fs.threads[key]
+	if !existed {
+		return nil
+	}
+	delete(fs.threads, key)
+	if err := fs.save(); err != nil {
+		fs.threads[key] = prev
+		return err
+	}
+	return nil
+}
+
+// save writes threads to temporary file and renames it to the path
+func (fs *FileThreadStore) save() error {
+	b, err := json.Marshal(fs.threads)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fs.path), filepath.Base(fs.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path)
+}
@@ -0,0 +1,135 @@
+package slackop
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+func countParent(parent *Message, replies int) *Message {
+	return &Message{Text: fmt.Sprintf("%s (%d more)", parent.Text, replies)}
+}
+
+func TestThreader(t *testing.T) {
+	s, c := newTestClient(t, slacktest.WithConversations(slacktest.Conversation{ID: "C1", Name: "feed"}))
+	th := NewThreader(c, "#feed", NewMemoryThreadStore(), WithParentUpdate(countParent))
+	ctx := context.Background()
+
+	parent, err := th.Post(ctx, "Cloud Run", &Message{Text: "Cloud Run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if _, err := th.Post(ctx, "Cloud Run", &Message{Text: fmt.Sprintf("tweet%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, err := th.Post(ctx, "Cloud Google", &Message{Text: "Cloud Google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := s.RequestsOf("chat.postMessage")
+	if len(posts) != 4 {
+		t.Fatalf("got %d posts, want 4", len(posts))
+	}
+	for i, want := range []string{"", parent.TS, parent.TS, ""} {
+		got := posts[i].Payload()
+		if ts, _ := got["thread_ts"].(string); ts != want {
+			t.Errorf("post %d: got thread_ts %q, want %q", i, ts, want)
+		}
+	}
+	if posts[1].Payload()["channel"] != "C1" {
+		t.Errorf("reply is not posted to channel of the parent: %v", posts[1].Payload())
+	}
+	if other.TS == parent.TS {
+		t.Error("other key is posted in the same thread")
+	}
+
+	updates := s.RequestsOf("chat.update")
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+	if got := updates[1].Payload(); got["ts"] != parent.TS || got["text"] != "Cloud Run (2 more)" {
+		t.Errorf("got update %v", got)
+	}
+}
+
+func TestThreader_EndAndTTL(t *testing.T) {
+	s, c := newTestClient(t)
+	th := NewThreader(c, "C1", NewMemoryThreadStore(), WithThreadTTL(time.Hour))
+	now := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	th.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	th.Post(ctx, "k", &Message{Text: "1"})
+	th.Post(ctx, "k", &Message{Text: "2"})
+	if err := th.End("k"); err != nil {
+		t.Fatal(err)
+	}
+	th.Post(ctx, "k", &Message{Text: "3"})
+	now = now.Add(2 * time.Hour)
+	th.Post(ctx, "k", &Message{Text: "4"})
+
+	var parents int
+	for _, r := range s.RequestsOf("chat.postMessage") {
+		if _, ok := r.Payload()["thread_ts"]; !ok {
+			parents++
+		}
+	}
+	if parents != 3 {
+		t.Errorf("got %d parents, want 3", parents)
+	}
+	if n := len(s.RequestsOf("chat.update")); n != 0 {
+		t.Errorf("got %d updates without WithParentUpdate", n)
+	}
+}
+
+func TestThreader_UpdateError(t *testing.T) {
+	s, c := newTestClient(t)
+	var failed string
+	th := NewThreader(c, "C1", NewMemoryThreadStore(), WithParentUpdate(countParent),
+		WithThreadErrorHandler(func(key string, err error) { failed = key }))
+	ctx := context.Background()
+
+	th.Post(ctx, "k", &Message{Text: "1"})
+	s.Inject("chat.update", slacktest.SlackError("cant_update_message"))
+	if _, err := th.Post(ctx, "k", &Message{Text: "2"}); err != nil {
+		t.Fatalf("got %v, want reply posted", err)
+	}
+	if failed != "k" {
+		t.Errorf("error handler got %q, want k", failed)
+	}
+}
+
+func TestFileThreadStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threads.json")
+	fs, err := OpenFileThreadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := ThreadRef{Channel: "C1", TS: "1.1", Replies: 3, Parent: &Message{Text: "Cloud Run"}}
+	if err := fs.Put("Cloud Run", ref); err != nil {
+		t.Fatal(err)
+	}
+	fs.Put("Cloud Google", ThreadRef{Channel: "C1", TS: "1.2"})
+	if err := fs.Delete("Cloud Google"); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = OpenFileThreadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := fs.Get("Cloud Run")
+	if !ok || err != nil || got.TS != "1.1" || got.Replies != 3 || got.Parent.Text != "Cloud Run" {
+		t.Errorf("got %+v %v %v", got, ok, err)
+	}
+	if _, ok, _ := fs.Get("Cloud Google"); ok {
+		t.Error("deleted thread is loaded")
+	}
+}
@@ -0,0 +1,213 @@
+package slackop
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits documented in https://api.slack.com/reference/block-kit
+const (
+	MaxBlocks            = 50
+	MaxAttachments       = 100
+	MaxMessageTextLength = 40000
+	MaxSectionTextLength = 3000
+	MaxSectionFields     = 10
+	MaxFieldTextLength   = 2000
+	MaxContextElements   = 10
+	MaxActionElements    = 25
+	MaxHeaderTextLength  = 150
+	MaxButtonTextLength  = 75
+	MaxButtonValueLength = 2000
+	MaxURLLength         = 3000
+	MaxAltTextLength     = 2000
+	MaxIDLength          = 255
+)
+
+// ValidationError lists violations of Slack limits found in Message
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid slack message: " + strings.Join(e.Problems, "; ")
+}
+
+// validator collects problems with path of the field
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) maxLen(path, s string, max int) {
+	if n := utf8.RuneCountInString(s); n > max {
+		v.addf(path, "length %d exceeds %d", n, max)
+	}
+}
+
+func (v *validator) required(path, s string) {
+	if s == "" {
+		v.addf(path, "must not be empty")
+	}
+}
+
+func (v *validator) text(path string, t *TextObject, max int) {
+	if t == nil {
+		v.addf(path, "must not be empty")
+		return
+	}
+	if t.Type != TextTypePlain && t.Type != TextTypeMarkdown {
+		v.addf(path+".type", "unknown text type %q", t.Type)
+	}
+	v.required(path+".text", t.Text)
+	v.maxLen(path+".text", t.Text, max)
+}
+
+func (v *validator) plainText(path string, t *TextObject, max int) {
+	v.text(path, t, max)
+	if t != nil && t.Type != TextTypePlain {
+		v.addf(path+".type", "must be %s", TextTypePlain)
+	}
+}
+
+// Validate checks m against the limits of Slack.
+// Returned error is *ValidationError listing every problem when m is invalid.
+func (m *Message) Validate() error {
+	v := &validator{}
+	v.maxLen("text", m.Text, MaxMessageTextLength)
+	if m.Text == "" && len(m.Blocks) == 0 && len(m.Attachments) == 0 {
+		v.addf("text", "must not be empty when blocks and attachments are empty")
+	}
+	v.blocks("blocks", m.Blocks)
+	if len(m.Attachments) > MaxAttachments {
+		v.addf("attachments", "count %d exceeds %d", len(m.Attachments), MaxAttachments)
+	}
+	for i, a := range m.Attachments {
+		p := fmt.Sprintf("attachments[%d]", i)
+		v.maxLen(p+".text", a.Text, MaxMessageTextLength)
+		v.maxLen(p+".title_link", a.TitleLink, MaxURLLength)
+		v.maxLen(p+".image_url", a.ImageURL, MaxURLLength)
+		v.blocks(p+".blocks", a.Blocks)
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *validator) blocks(path string, blocks []Block) {
+	if len(blocks) > MaxBlocks {
+		v.addf(path, "count %d exceeds %d", len(blocks), MaxBlocks)
+	}
+	for i, b := range blocks {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if isNil(b) {
+			v.addf(p, "must not be nil")
+			continue
+		}
+		switch b := b.(type) {
+		case *SectionBlock:
+			v.blockID(p, b.BlockID)
+			if b.Text == nil && len(b.Fields) == 0 {
+				v.addf(p, "either text or fields is required")
+			}
+			if b.Text != nil {
+				v.text(p+".text", b.Text, MaxSectionTextLength)
+			}
+			if len(b.Fields) > MaxSectionFields {
+				v.addf(p+".fields", "count %d exceeds %d", len(b.Fields), MaxSectionFields)
+			}
+			for j, f := range b.Fields {
+				v.text(fmt.Sprintf("%s.fields[%d]", p, j), f, MaxFieldTextLength)
+			}
+			if b.Accessory != nil {
+				v.element(p+".accessory", b.Accessory)
+			}
+		case *DividerBlock:
+			v.blockID(p, b.BlockID)
+		case *ContextBlock:
+			v.blockID(p, b.BlockID)
+			if len(b.Elements) == 0 {
+				v.addf(p+".elements", "must not be empty")
+			}
+			if len(b.Elements) > MaxContextElements {
+				v.addf(p+".elements", "count %d exceeds %d", len(b.Elements), MaxContextElements)
+			}
+			for j, e := range b.Elements {
+				ep := fmt.Sprintf("%s.elements[%d]", p, j)
+				switch e := e.(type) {
+				case *TextObject:
+					v.text(ep, e, MaxSectionTextLength)
+				case *ImageElement:
+					v.element(ep, e)
+				}
+			}
+		case *ActionsBlock:
+			v.blockID(p, b.BlockID)
+			if len(b.Elements) == 0 {
+				v.addf(p+".elements", "must not be empty")
+			}
+			if len(b.Elements) > MaxActionElements {
+				v.addf(p+".elements", "count %d exceeds %d", len(b.Elements), MaxActionElements)
+			}
+			for j, e := range b.Elements {
+				v.element(fmt.Sprintf("%s.elements[%d]", p, j), e)
+			}
+		case *ImageBlock:
+			v.blockID(p, b.BlockID)
+			v.required(p+".image_url", b.ImageURL)
+			v.maxLen(p+".image_url", b.ImageURL, MaxURLLength)
+			v.required(p+".alt_text", b.AltText)
+			v.maxLen(p+".alt_text", b.AltText, MaxAltTextLength)
+			if b.Title != nil {
+				v.plainText(p+".title", b.Title, MaxSectionTextLength)
+			}
+		case *HeaderBlock:
+			v.blockID(p, b.BlockID)
+			v.plainText(p+".text", b.Text, MaxHeaderTextLength)
+		default:
+			v.addf(p, "unknown block type %q", b.BlockType())
+		}
+	}
+}
+
+func (v *validator) blockID(path, id string) {
+	v.maxLen(path+".block_id", id, MaxIDLength)
+}
+
+func (v *validator) element(path string, e Element) {
+	if isNil(e) {
+		v.addf(path, "must not be nil")
+		return
+	}
+	switch e := e.(type) {
+	case *ButtonElement:
+		v.plainText(path+".text", e.Text, MaxButtonTextLength)
+		v.maxLen(path+".action_id", e.ActionID, MaxIDLength)
+		v.maxLen(path+".url", e.URL, MaxURLLength)
+		v.maxLen(path+".value", e.Value, MaxButtonValueLength)
+		if e.Style != "" && e.Style != ButtonStylePrimary && e.Style != ButtonStyleDanger {
+			v.addf(path+".style", "unknown style %q", e.Style)
+		}
+	case *ImageElement:
+		v.required(path+".image_url", e.ImageURL)
+		v.maxLen(path+".image_url", e.ImageURL, MaxURLLength)
+		v.required(path+".alt_text", e.AltText)
+		v.maxLen(path+".alt_text", e.AltText, MaxAltTextLength)
+	default:
+		v.addf(path, "unknown element type %q", e.ElementType())
+	}
+}
+
+// isNil reports whether x is nil or nil pointer such as (*SectionBlock)(nil)
+func isNil(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	rv := reflect.ValueOf(x)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
@@ -0,0 +1,238 @@
+package slackop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultAPIURL is base URL of Slack Web API
+const DefaultAPIURL = "https://slack.com/api/"
+
+// Client calls Slack Web API with bot token.
+// Unlike incoming webhook, it can choose channel, reply in thread and edit messages.
+type Client struct {
+	token      string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// ClientOption configures Client
+type ClientOption func(*Client)
+
+// WithAPIURL changes base URL of Web API such as URL of fake server in tests
+func WithAPIURL(apiURL string) ClientOption {
+	return func(c *Client) {
+		c.apiURL = strings.TrimSuffix(apiURL, "/") + "/"
+	}
+}
+
+// WithClientHTTPClient sets http.Client used for the calls
+func WithClientHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// NewClient gives Client authorized by bot token such as "xoxb-..."
+func NewClient(token string, opts ...ClientOption) *Client {
+	c := &Client{
+		token:      token,
+		apiURL:     DefaultAPIURL,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// apiResponse is envelope of every Web API response
+type apiResponse struct {
+	OK               bool   `json:"ok"`
+	Error            string `json:"error"`
+	Warning          string `json:"warning"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// PostMessageResponse is result of chat.postMessage
+type PostMessageResponse struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// UpdateMessageResponse is result of chat.update
+type UpdateMessageResponse struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Text    string `json:"text"`
+}
+
+// DeleteMessageResponse is result of chat.delete
+type DeleteMessageResponse struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostEphemeralResponse is result of chat.postEphemeral
+type PostEphemeralResponse struct {
+	MessageTS string `json:"message_ts"`
+}
+
+// Conversation is a channel given by conversations.list
+type Conversation struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IsChannel  bool   `json:"is_channel"`
+	IsPrivate  bool   `json:"is_private"`
+	IsArchived bool   `json:"is_archived"`
+	IsMember   bool   `json:"is_member"`
+	NumMembers int    `json:"num_members"`
+}
+
+// ListConversationsParams is parameter of conversations.list.
+// Types is comma separated list such as "public_channel,private_channel".
+type ListConversationsParams struct {
+	Cursor          string
+	Limit           int
+	Types           string
+	ExcludeArchived bool
+}
+
+// ListConversationsResponse is result of conversations.list.
+// NextCursor is empty when there is no more page.
+type ListConversationsResponse struct {
+	Channels   []Conversation `json:"channels"`
+	NextCursor string         `json:"-"`
+}
+
+// PostMessage posts msg to channel by chat.postMessage.
+// msg.ThreadTS makes it a reply in the thread.
+func (c *Client) PostMessage(ctx context.Context, channel string, msg *Message) (*PostMessageResponse, error) {
+	body := *msg
+	body.Channel = channel
+	res := &PostMessageResponse{}
+	if _, err := c.postJSON(ctx, "chat.postMessage", &body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateMessage replaces the message at ts in channel by chat.update
+func (c *Client) UpdateMessage(ctx context.Context, channel, ts string, msg *Message) (*UpdateMessageResponse, error) {
+	m := *msg
+	m.Channel = channel
+	body := struct {
+		*Message
+		TS string `json:"ts"`
+	}{Message: &m, TS: ts}
+	res := &UpdateMessageResponse{}
+	if _, err := c.postJSON(ctx, "chat.update", &body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteMessage deletes the message at ts in channel by chat.delete
+func (c *Client) DeleteMessage(ctx context.Context, channel, ts string) (*DeleteMessageResponse, error) {
+	body := map[string]string{"channel": channel, "ts": ts}
+	res := &DeleteMessageResponse{}
+	if _, err := c.postJSON(ctx, "chat.delete", body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PostEphemeral posts msg visible only to user in channel by chat.postEphemeral
+func (c *Client) PostEphemeral(ctx context.Context, channel, user string, msg *Message) (*PostEphemeralResponse, error) {
+	m := *msg
+	m.Channel = channel
+	body := struct {
+		*Message
+		User string `json:"user"`
+	}{Message: &m, User: user}
+	res := &PostEphemeralResponse{}
+	if _, err := c.postJSON(ctx, "chat.postEphemeral", &body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListConversations gives a page of channels by conversations.list
+func (c *Client) ListConversations(ctx context.Context, p ListConversationsParams) (*ListConversationsResponse, error) {
+	q := url.Values{}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Types != "" {
+		q.Set("types", p.Types)
+	}
+	if p.ExcludeArchived {
+		q.Set("exclude_archived", "true")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+"conversations.list?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res := &ListConversationsResponse{}
+	env, err := c.do(req, "conversations.list", res)
+	if err != nil {
+		return nil, err
+	}
+	res.NextCursor = env.ResponseMetadata.NextCursor
+	return res, nil
+}
+
+// postJSON calls method with body encoded as JSON
+func (c *Client) postJSON(ctx context.Context, method string, body interface{}, out interface{}) (*apiResponse, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %v", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+method, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return c.do(req, method, out)
+}
+
+// do sends req and decodes the response into out when Slack reports ok
+func (c *Client) do(req *http.Request, method string, out interface{}) (*apiResponse, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	env := &apiResponse{}
+	if err := json.Unmarshal(b, env); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, newAPIError(method, resp, "")
+		}
+		return nil, fmt.Errorf("failed to decode %s response: %v", method, err)
+	}
+	if !env.OK || resp.StatusCode >= 300 {
+		return nil, newAPIError(method, resp, env.Error)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %v", method, err)
+	}
+	return env, nil
+}
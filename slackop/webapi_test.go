@@ -0,0 +1,135 @@
+package slackop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fckey/go-sandbox/slackop/slacktest"
+)
+
+// newTestClient starts fake Slack and gives Client calling it
+func newTestClient(t *testing.T, opts ...slacktest.Option) (*slacktest.Server, *Client) {
+	t.Helper()
+	s := slacktest.NewServer(opts...)
+	t.Cleanup(s.Close)
+	return s, NewClient(slacktest.DefaultToken, WithAPIURL(s.APIURL()))
+}
+
+func TestClient_PostMessage(t *testing.T) {
+	s, c := newTestClient(t, slacktest.WithConversations(slacktest.Conversation{ID: "C123", Name: "feed"}))
+
+	msg, _ := NewMessageBuilder("hello").ThreadTS("1503435950.000100").Section("*hello*").Build()
+	res, err := c.PostMessage(context.Background(), "#feed", msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Channel != "C123" || res.TS == "" {
+		t.Errorf("unexpected response: %+v", res)
+	}
+	got := s.RequestsOf("chat.postMessage")[0].Payload()
+	if got["channel"] != "#feed" || got["thread_ts"] != "1503435950.000100" || got["text"] != "hello" {
+		t.Errorf("unexpected request: %v", got)
+	}
+	if msg.Channel != "" {
+		t.Error("given message was modified")
+	}
+}
+
+func TestClient_UpdateAndDelete(t *testing.T) {
+	s, c := newTestClient(t)
+	ctx := context.Background()
+
+	posted, err := c.PostMessage(ctx, "C123", &Message{Text: "hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ures, err := c.UpdateMessage(ctx, "C123", posted.TS, &Message{Text: "edited"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated := s.RequestsOf("chat.update")[0].Payload()
+	if ures.Text != "edited" || updated["ts"] != posted.TS || updated["channel"] != "C123" {
+		t.Errorf("unexpected update: %+v %v", ures, updated)
+	}
+	if _, err := c.DeleteMessage(ctx, "C123", posted.TS); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.DeleteMessage(ctx, "C123", posted.TS); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("got %v, want %v", err, ErrMessageNotFound)
+	}
+	eres, err := c.PostEphemeral(ctx, "C123", "U1", &Message{Text: "only you"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := s.RequestsOf("chat.postEphemeral")[0].Payload(); got["user"] != "U1" {
+		t.Errorf("user: got %v, want U1", got["user"])
+	}
+	if eres.MessageTS == "" {
+		t.Error("message_ts is empty")
+	}
+}
+
+func TestClient_ListConversations(t *testing.T) {
+	_, c := newTestClient(t, slacktest.WithConversations(
+		slacktest.Conversation{ID: "C1", Name: "feed", IsMember: true},
+		slacktest.Conversation{ID: "C2", Name: "old", IsArchived: true},
+		slacktest.Conversation{ID: "C3", Name: "oncall"},
+	))
+
+	var names []string
+	p := ListConversationsParams{Limit: 1, ExcludeArchived: true}
+	for {
+		res, err := c.ListConversations(context.Background(), p)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, ch := range res.Channels {
+			names = append(names, ch.Name)
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		p.Cursor = res.NextCursor
+	}
+	if len(names) != 2 || names[0] != "feed" || names[1] != "oncall" {
+		t.Errorf("unexpected channels: %v", names)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	s, c := newTestClient(t, slacktest.WithConversations(slacktest.Conversation{ID: "C1", Name: "feed"}))
+	ctx := context.Background()
+
+	_, err := c.PostMessage(ctx, "#nowhere", &Message{Text: "hello"})
+	if !errors.Is(err, ErrChannelNotFound) {
+		t.Errorf("got %v, want %v", err, ErrChannelNotFound)
+	}
+
+	s.Inject("chat.delete", slacktest.RateLimited(30*time.Second))
+	_, err = c.DeleteMessage(ctx, "C1", "1.1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want %v", err, ErrRateLimited)
+	}
+	if apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("retry after: got %v, want %v", apiErr.RetryAfter, 30*time.Second)
+	}
+
+	s.Inject("chat.postMessage", slacktest.ServerError(http.StatusServiceUnavailable))
+	if _, err := c.PostMessage(ctx, "#feed", &Message{Text: "hello"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+
+	s.Inject("chat.postMessage", slacktest.SlackError("msg_too_long"))
+	if _, err := c.PostMessage(ctx, "#feed", &Message{Text: "hello"}); !errors.Is(err, ErrMsgTooLong) {
+		t.Errorf("got %v, want %v", err, ErrMsgTooLong)
+	}
+
+	bad := NewClient("xoxb-wrong", WithAPIURL(s.APIURL()))
+	if _, err := bad.PostMessage(ctx, "#feed", &Message{Text: "hello"}); !errors.Is(err, ErrInvalidAuth) {
+		t.Errorf("got %v, want %v", err, ErrInvalidAuth)
+	}
+}